@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// allTargetsHandler collects every target of the config file into one response, labeling the
+// metrics of each with its target. At most --web.all-targets-concurrency targets are collected
+// at the same time.
+func (c *SherlockCollector) allTargetsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := scrapeContext(r)
+	defer cancel()
+
+	concurrency := *allTargetsConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+
+	// Every target gets a registry of its own, since targets with group labels or aggregated
+	// chassis expose the same metrics with more labels than the others
+	var gatherers concurrentGatherers
+	var hosts []string
+	for _, target := range c.config.Targets {
+		hosts = append(hosts, target.Host)
+		done := scrapes.start(target.Host)
+		defer done()
+
+		labels := prometheus.Labels{"target": target.Host}
+		for name, value := range c.config.LabelsFor(target.Host) {
+			labels[name] = value
+		}
+		registry := prometheus.NewRegistry()
+		c.registerTarget(ctx, prometheus.WrapRegistererWith(labels, registry), target.Host, "", slots)
+		gatherers = append(gatherers, registry)
+	}
+
+	// The exporter metrics are gathered after the targets, so that they reflect the collections
+	// of this request
+	h := promhttp.HandlerFor(prometheus.Gatherers{gatherers, c.exporterMetricsOf(hosts...)}, promhttp.HandlerOpts{})
+	h.ServeHTTP(w, r)
+}
+
+// concurrentGatherers gathers all of its gatherers at the same time, merging their metric families
+// like prometheus.Gatherers, which gathers them one after another
+type concurrentGatherers []prometheus.Gatherer
+
+// Gather implements prometheus.Gatherer
+func (g concurrentGatherers) Gather() ([]*dto.MetricFamily, error) {
+	gathered := make(prometheus.Gatherers, len(g))
+
+	var wg sync.WaitGroup
+	for i, gatherer := range g {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			families, err := gatherer.Gather()
+			gathered[i] = prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+				return families, err
+			})
+		}()
+	}
+	wg.Wait()
+
+	return gathered.Gather()
+}
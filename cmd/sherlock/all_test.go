@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mllnd/sherlock/internal/config"
+)
+
+func TestAllTargetsHandlerWithMixedLabels(t *testing.T) {
+	// Nothing listens on these ports, only the registration of the targets is exercised
+	c := newTestCollector(t, "127.0.0.1:1")
+	c.config.Groups = []config.TargetGroup{{
+		Name:   "edge",
+		Hosts:  []string{"127.0.0.1:2"},
+		Labels: map[string]string{"site": "edge"},
+	}}
+	c.config.Targets = append(c.config.Targets, config.TargetConfig{Host: "127.0.0.1:2", Group: "edge"})
+
+	recorder := httptest.NewRecorder()
+	c.allTargetsHandler(recorder, httptest.NewRequest(http.MethodGet, "/metrics/all", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+	}
+}
+
+func TestAllTargetsHandlerCollectsTargetsConcurrently(t *testing.T) {
+	// Each BMC holds its service root until both are being connected to at the same time
+	var arrived atomic.Int32
+	var sequential atomic.Bool
+	overlapping := make(chan struct{})
+	wait := func() {
+		if arrived.Add(1) == 2 {
+			close(overlapping)
+		}
+		select {
+		case <-overlapping:
+		case <-time.After(2 * time.Second):
+			sequential.Store(true)
+		}
+	}
+	first, second := newFakeBMC(wait), newFakeBMC(wait)
+	defer first.Close()
+	defer second.Close()
+
+	c := newTestCollector(t, strings.TrimPrefix(first.URL, "https://"), strings.TrimPrefix(second.URL, "https://"))
+
+	recorder := httptest.NewRecorder()
+	c.allTargetsHandler(recorder, httptest.NewRequest(http.MethodGet, "/metrics/all", nil))
+
+	if sequential.Load() {
+		t.Error("the targets were collected one after another")
+	}
+}
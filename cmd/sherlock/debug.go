@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"path"
+
+	"github.com/mllnd/sherlock/internal/config"
+	"github.com/mllnd/sherlock/internal/redfish"
+)
+
+// requireAuth wraps a handler with HTTP basic authentication against the admin credentials
+func requireAuth(cfg *config.Config, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(cfg.AdminUsername)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(cfg.AdminPassword)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="sherlock"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// debugRedfishHandler proxies a raw GET for a Redfish resource through the target's client
+func (c *SherlockCollector) debugRedfishHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Error: only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	target, ok := c.targetParam(w, r)
+	if !ok {
+		return
+	}
+
+	// Only allow clean paths below the Redfish service root of the target
+	resourcePath := r.URL.Query().Get("path")
+	if resourcePath == "" || path.Clean(resourcePath) != resourcePath || !redfish.IsServicePath(resourcePath) {
+		http.Error(w, "Error: 'path' parameter must be a clean path below /redfish/v1", http.StatusBadRequest)
+		return
+	}
+
+	client, err := c.getClient(target)
+	if err != nil {
+		c.logger.Error("failed to connect to redfish api", "target", target, "error", err)
+		http.Error(w, "Error: failed to connect to target", http.StatusBadGateway)
+		return
+	}
+
+	body, err := client.GetRaw(resourcePath)
+	if err != nil {
+		c.logger.Debug("debug request failed", "target", target, "path", resourcePath, "error", err)
+		http.Error(w, "Error: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugRedfishHandlerChecksTheTarget(t *testing.T) {
+	c := newTestCollector(t, "10.0.0.1")
+	c.config.RestrictTargets = true
+
+	previous := listenAddresses
+	listenAddresses = addressList{"127.0.0.1:9290"}
+	defer func() { listenAddresses = previous }()
+
+	for _, tt := range []struct {
+		target string
+		want   int
+	}{
+		{"", http.StatusBadRequest},
+		{"127.0.0.1:9290", http.StatusBadRequest},
+		{"10.0.0.2", http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		c.debugRedfishHandler(w, httptest.NewRequest(http.MethodGet, "/debug/redfish?path=/redfish/v1&target="+tt.target, nil))
+		if w.Code != tt.want {
+			t.Errorf("target %q: got status %d, want %d", tt.target, w.Code, tt.want)
+		}
+	}
+}
+
+func TestDebugRedfishHandlerChecksThePath(t *testing.T) {
+	c := newTestCollector(t, "10.0.0.1")
+
+	for _, resourcePath := range []string{"", "/redfish/v1foo", "/redfish/v1/../v1foo", "/other"} {
+		w := httptest.NewRecorder()
+		c.debugRedfishHandler(w, httptest.NewRequest(http.MethodGet, "/debug/redfish?target=10.0.0.1&path="+resourcePath, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("path %q: got status %d, want %d", resourcePath, w.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mllnd/sherlock/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fqNameRE extracts the fully-qualified metric name from a descriptor's string form
+var fqNameRE = regexp.MustCompile(`fqName: "([^"]*)"`)
+
+// checkDuplicateMetrics fails if more than one collector describes a metric with the same name,
+// which would make every scrape fail on registration
+func checkDuplicateMetrics(collectors []collector.Collector) error {
+	owners := make(map[string][]string)
+	for _, c := range collectors {
+		owner := fmt.Sprintf("%T", c)
+
+		ch := make(chan *prometheus.Desc)
+		go func() {
+			c.Describe(ch)
+			close(ch)
+		}()
+
+		seen := make(map[string]bool)
+		for desc := range ch {
+			match := fqNameRE.FindStringSubmatch(desc.String())
+			if match == nil || seen[match[1]] {
+				continue
+			}
+			seen[match[1]] = true
+			owners[match[1]] = append(owners[match[1]], owner)
+		}
+	}
+
+	var duplicates []string
+	for name, collectors := range owners {
+		if len(collectors) > 1 {
+			duplicates = append(duplicates, fmt.Sprintf("%s (%s)", name, strings.Join(collectors, ", ")))
+		}
+	}
+	if len(duplicates) > 0 {
+		sort.Strings(duplicates)
+		return fmt.Errorf("metrics exposed by more than one collector: %s", strings.Join(duplicates, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// graphiteInvalidChars matches the characters that can't appear in a Graphite path segment
+var graphiteInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// graphiteHandler renders the metrics of a target in the Graphite plaintext format
+func (c *SherlockCollector) graphiteHandler(w http.ResponseWriter, r *http.Request) {
+	target, ok := c.targetParam(w, r)
+	if !ok {
+		return
+	}
+
+	done := scrapes.start(target)
+	defer done()
+
+	ctx, cancel := scrapeContext(r)
+	defer cancel()
+
+	families, err := c.targetGatherer(ctx, target, r.URL.Query().Get("chassis")).Gather()
+	if err != nil {
+		c.logger.Error("failed to gather metrics", "target", target, "error", err)
+		if len(families) == 0 {
+			http.Error(w, "Error: failed to gather metrics", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writeGraphite(w, families, time.Now())
+}
+
+// writeGraphite writes metric families as "path value timestamp" lines. Labels become
+// dotted name.value path segments in label name order. Labels with an empty value are left out,
+// like Prometheus treats them as absent, since they would make an empty path segment.
+func writeGraphite(w io.Writer, families []*dto.MetricFamily, now time.Time) {
+	timestamp := now.Unix()
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			var value float64
+			switch family.GetType() {
+			case dto.MetricType_GAUGE:
+				value = metric.GetGauge().GetValue()
+			case dto.MetricType_COUNTER:
+				value = metric.GetCounter().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = metric.GetUntyped().GetValue()
+			default:
+				continue
+			}
+
+			labels := metric.GetLabel()
+			sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+
+			segments := []string{graphiteSegment(family.GetName())}
+			for _, label := range labels {
+				if label.GetValue() == "" {
+					continue
+				}
+				segments = append(segments, graphiteSegment(label.GetName()), graphiteSegment(label.GetValue()))
+			}
+
+			fmt.Fprintf(w, "%s %g %d\n", strings.Join(segments, "."), value, timestamp)
+		}
+	}
+}
+
+// graphiteSegment makes a string safe to use as a single Graphite path segment
+func graphiteSegment(s string) string {
+	return graphiteInvalidChars.ReplaceAllString(s, "_")
+}
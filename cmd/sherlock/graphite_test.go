@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestWriteGraphiteSkipsEmptyLabels(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	location := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ipmi_chassis_location_info",
+		Help: "Location of the chassis",
+	}, []string{"rack", "asset_tag"})
+	location.WithLabelValues("R1.2", "").Set(1)
+	registry.MustRegister(location)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+
+	var out strings.Builder
+	writeGraphite(&out, families, time.Unix(1700000000, 0))
+
+	if want := "ipmi_chassis_location_info.rack.R1_2 1 1700000000\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net"
+)
+
+// isSelfTarget reports whether the target resolves to the exporter's own listen address
+func isSelfTarget(target, listenAddress string) bool {
+	targetHost, targetPort, err := net.SplitHostPort(target)
+	if err != nil {
+		// Targets without a port are scraped over HTTPS
+		targetHost, targetPort = target, "443"
+	}
+
+	listenHost, listenPort, err := net.SplitHostPort(listenAddress)
+	if err != nil || targetPort != listenPort {
+		return false
+	}
+
+	targetIPs := resolveHost(targetHost)
+	if len(targetIPs) == 0 {
+		return false
+	}
+
+	// An unspecified listen address accepts connections on every local address
+	var localIPs []net.IP
+	if ip := net.ParseIP(listenHost); listenHost == "" || (ip != nil && ip.IsUnspecified()) {
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			return false
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				localIPs = append(localIPs, ipNet.IP)
+			}
+		}
+	} else {
+		localIPs = resolveHost(listenHost)
+	}
+
+	for _, targetIP := range targetIPs {
+		for _, localIP := range localIPs {
+			if targetIP.Equal(localIP) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveHost returns the IP addresses of a hostname or literal IP
+func resolveHost(host string) []net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+
+	addrs, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	return addrs
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// targetIdleTimeout is how long a target goes without scrapes before its history and series are
+// dropped, so that scrapes of arbitrary ?target= values don't add series forever
+var targetIdleTimeout = time.Hour
+
+// scrapeHistory keeps the results of the last scrapes of each target
+type scrapeHistory struct {
+	mutex   sync.Mutex
+	window  int
+	targets map[string]*scrapeResults
+}
+
+// scrapeResults is a ring buffer of scrape results, true for a successful scrape
+type scrapeResults struct {
+	results []bool
+	next    int
+	scraped time.Time
+}
+
+// newScrapeHistory creates a scrapeHistory keeping the last window results of each target
+func newScrapeHistory(window int) *scrapeHistory {
+	return &scrapeHistory{
+		window:  window,
+		targets: make(map[string]*scrapeResults),
+	}
+}
+
+// touch marks the target as scraped now and forgets the targets that haven't been scraped for
+// targetIdleTimeout, returning them
+func (h *scrapeHistory) touch(target string, now time.Time) []string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.results(target).scraped = now
+
+	var idle []string
+	for other, ring := range h.targets {
+		if other != target && now.Sub(ring.scraped) >= targetIdleTimeout {
+			delete(h.targets, other)
+			idle = append(idle, other)
+		}
+	}
+	return idle
+}
+
+// record adds the result of a scrape of the target and returns the success ratio over the window
+func (h *scrapeHistory) record(target string, success bool) float64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	ring := h.results(target)
+
+	// Fill the window first, then overwrite the oldest result
+	if len(ring.results) < h.window {
+		ring.results = append(ring.results, success)
+	} else {
+		ring.results[ring.next] = success
+		ring.next = (ring.next + 1) % h.window
+	}
+
+	succeeded := 0
+	for _, result := range ring.results {
+		if result {
+			succeeded++
+		}
+	}
+	return float64(succeeded) / float64(len(ring.results))
+}
+
+// results returns the results of the target, creating them if needed. The caller must hold the
+// mutex.
+func (h *scrapeHistory) results(target string) *scrapeResults {
+	ring, ok := h.targets[target]
+	if !ok {
+		ring = &scrapeResults{results: make([]bool, 0, h.window)}
+		h.targets[target] = ring
+	}
+	return ring
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// scrapes tracks the scrapes currently being served
+var scrapes = &inFlightScrapes{targets: make(map[string]int)}
+
+// inFlightScrapes counts the in-flight scrapes per target, so that shutdown can wait for them
+type inFlightScrapes struct {
+	mutex   sync.Mutex
+	targets map[string]int
+	wg      sync.WaitGroup
+}
+
+// start records a scrape of the target and returns a function that marks it as finished
+func (s *inFlightScrapes) start(target string) func() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.targets[target]++
+	s.wg.Add(1)
+	scrapesInFlight.Inc()
+
+	return func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+
+		if s.targets[target]--; s.targets[target] <= 0 {
+			delete(s.targets, target)
+		}
+		scrapesInFlight.Dec()
+		s.wg.Done()
+	}
+}
+
+// wait waits until no scrape is in flight, or until the context is done
+func (s *inFlightScrapes) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// active returns the targets with an in-flight scrape
+func (s *inFlightScrapes) active() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	targets := make([]string, 0, len(s.targets))
+	for target := range s.targets {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// requestSlots limits the scrapes served concurrently, created on first use from --web.max-requests
+var (
+	requestSlots     *fairSlots
+	requestSlotsOnce sync.Once
+)
+
+// fairSlots is a fixed number of slots handed out fairly between targets. Once all slots are
+// taken, waiting requests are queued per target and every freed slot goes to the next target in
+// turn, so that a target scraped by many clients can't starve the others.
+type fairSlots struct {
+	mutex   sync.Mutex
+	free    int
+	waiting map[string][]chan struct{}
+	turns   []string
+}
+
+// newFairSlots creates the given number of free slots
+func newFairSlots(slots int) *fairSlots {
+	return &fairSlots{free: slots, waiting: make(map[string][]chan struct{})}
+}
+
+// acquire takes a slot for the target, queueing for at most wait. It returns a function that
+// releases the slot, or false if none became free in time or the context was cancelled.
+func (s *fairSlots) acquire(ctx context.Context, target string, wait time.Duration) (func(), bool) {
+	s.mutex.Lock()
+	if s.free > 0 && len(s.turns) == 0 {
+		s.free--
+		s.mutex.Unlock()
+		return s.release, true
+	}
+	if wait <= 0 {
+		s.mutex.Unlock()
+		return nil, false
+	}
+
+	granted := make(chan struct{})
+	if len(s.waiting[target]) == 0 {
+		s.turns = append(s.turns, target)
+	}
+	s.waiting[target] = append(s.waiting[target], granted)
+	scrapeQueueDepth.WithLabelValues(target).Inc()
+	s.mutex.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-granted:
+		return s.release, true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	select {
+	case <-granted:
+		// The slot was handed over while giving up, pass it on
+		s.handOver()
+	default:
+		s.dequeue(target, granted)
+	}
+	return nil, false
+}
+
+// release frees a slot, handing it to the next waiting target if there is one
+func (s *fairSlots) release() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.handOver()
+}
+
+// handOver gives a free slot to the oldest request of the target whose turn it is, which then
+// moves to the back of the turns if it has further requests waiting
+func (s *fairSlots) handOver() {
+	if len(s.turns) == 0 {
+		s.free++
+		return
+	}
+
+	target := s.turns[0]
+	s.turns = s.turns[1:]
+	granted := s.waiting[target][0]
+	s.dequeue(target, granted)
+	if len(s.waiting[target]) > 0 {
+		s.turns = append(s.turns, target)
+	}
+	close(granted)
+}
+
+// dequeue removes a waiting request of the target, along with the target's turn once it has
+// none left
+func (s *fairSlots) dequeue(target string, granted chan struct{}) {
+	waiting := s.waiting[target]
+	for i, ch := range waiting {
+		if ch == granted {
+			waiting = append(waiting[:i], waiting[i+1:]...)
+			break
+		}
+	}
+
+	if len(waiting) > 0 {
+		s.waiting[target] = waiting
+		scrapeQueueDepth.WithLabelValues(target).Set(float64(len(waiting)))
+		return
+	}
+
+	delete(s.waiting, target)
+	scrapeQueueDepth.DeleteLabelValues(target)
+	for i, turn := range s.turns {
+		if turn == target {
+			s.turns = append(s.turns[:i], s.turns[i+1:]...)
+			break
+		}
+	}
+}
+
+// limitRequests limits the scrapes served at the same time to --web.max-requests. Once they are
+// all taken, further requests queue for up to --web.queue-timeout, taking turns between targets,
+// and are rejected with 503 if no slot becomes free. The response is streamed by promhttp, but
+// every scrape holds the gathered metrics of its target in memory, so this bounds the memory used
+// by many simultaneous scrapes of large targets.
+func limitRequests(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if *maxRequests <= 0 {
+			next(w, r)
+			return
+		}
+
+		requestSlotsOnce.Do(func() {
+			requestSlots = newFairSlots(*maxRequests)
+		})
+
+		// Requests without a target, e.g. of all targets, queue as one under their path
+		target := normalizeTarget(r.URL.Query().Get("target"))
+		if target == "" {
+			target = r.URL.Path
+		}
+
+		release, ok := requestSlots.acquire(r.Context(), target, *queueTimeout)
+		if !ok {
+			http.Error(w, "Too many concurrent scrapes, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		defer release()
+		next(w, r)
+	}
+}
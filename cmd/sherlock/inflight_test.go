@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// withMaxRequests sets --web.max-requests for a test, with fresh request slots
+func withMaxRequests(t *testing.T, n int) {
+	t.Helper()
+
+	previous, previousTimeout := *maxRequests, *queueTimeout
+	*maxRequests, *queueTimeout = n, 0
+	requestSlots, requestSlotsOnce = nil, sync.Once{}
+	t.Cleanup(func() {
+		*maxRequests, *queueTimeout = previous, previousTimeout
+		requestSlots, requestSlotsOnce = nil, sync.Once{}
+	})
+}
+
+func TestLimitRequestsRejectsScrapesBeyondTheLimit(t *testing.T) {
+	withMaxRequests(t, 2)
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	handler := limitRequests(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-finish
+	})
+
+	// Take both slots with scrapes of different targets
+	var wg sync.WaitGroup
+	for _, target := range []string{"10.0.0.1", "10.0.0.2"} {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics?target="+target, nil))
+		}(target)
+		<-started
+	}
+
+	rejected := httptest.NewRecorder()
+	handler(rejected, httptest.NewRequest(http.MethodGet, "/metrics?target=10.0.0.3", nil))
+	if rejected.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d beyond the limit, want 503", rejected.Code)
+	}
+
+	close(finish)
+	wg.Wait()
+
+	// The freed slots serve further scrapes
+	go func() { <-started }()
+	served := httptest.NewRecorder()
+	handler(served, httptest.NewRequest(http.MethodGet, "/metrics?target=10.0.0.3", nil))
+	if served.Code != http.StatusOK {
+		t.Errorf("got status %d once slots were free, want 200", served.Code)
+	}
+}
+
+func TestLimitRequestsUnlimitedByDefault(t *testing.T) {
+	withMaxRequests(t, 0)
+
+	const scrapes = 50
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	handler := limitRequests(func(w http.ResponseWriter, r *http.Request) {
+		wg.Done()
+		<-release
+	})
+
+	// Every scrape is served at the same time, otherwise this waits forever
+	wg.Add(scrapes)
+	for i := 0; i < scrapes; i++ {
+		go handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics?target=10.0.0.1", nil))
+	}
+	wg.Wait()
+	close(release)
+}
+
+func TestInFlightScrapesWait(t *testing.T) {
+	s := &inFlightScrapes{targets: make(map[string]int)}
+	done := s.start("10.0.0.1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v while a scrape is in flight, want the deadline", err)
+	}
+
+	go done()
+	if err := s.wait(context.Background()); err != nil {
+		t.Errorf("got %v once the scrape finished, want nil", err)
+	}
+	if active := s.active(); len(active) != 0 {
+		t.Errorf("got in-flight scrapes of %v, want none", active)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mllnd/sherlock/internal/redfish"
+)
+
+// keepAlive pings the service root of every client each interval until the context is canceled,
+// so that BMC sessions don't expire between infrequent scrapes
+func (c *SherlockCollector) keepAlive(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.pingClients()
+		}
+	}
+}
+
+// pingClients pings all clients concurrently, reconnecting those whose session expired
+func (c *SherlockCollector) pingClients() {
+	c.mutex.Lock()
+	clients := make(map[string]*redfish.Client, len(c.clients))
+	for target, client := range c.clients {
+		clients[target] = client
+	}
+	c.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for target, client := range clients {
+		wg.Add(1)
+		go func(target string, client *redfish.Client) {
+			defer wg.Done()
+			if _, err := client.Ping(); err != nil {
+				c.logger.Debug("keep-alive ping failed", "target", target, "error", err)
+			}
+		}(target, client)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mllnd/sherlock/internal/collector"
+	"github.com/mllnd/sherlock/internal/redfish"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/goleak"
+)
+
+// blockingCollector is a collector whose update blocks until it's released
+type blockingCollector struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingCollector) Update(*redfish.Client) error {
+	close(c.started)
+	<-c.release
+	return nil
+}
+
+func (c *blockingCollector) Describe(chan<- *prometheus.Desc) {}
+func (c *blockingCollector) Collect(chan<- prometheus.Metric) {}
+func (c *blockingCollector) SetTarget(string)                 {}
+func (c *blockingCollector) SetChassis(string)                {}
+func (c *blockingCollector) SetContext(context.Context)       {}
+func (c *blockingCollector) Name() string                     { return "blocking" }
+
+func TestCancelledScrapeDoesNotLeakGoroutines(t *testing.T) {
+	bmc := newFakeBMC(nil)
+	defer bmc.Close()
+
+	target := strings.TrimPrefix(bmc.URL, "https://")
+	c := newTestCollector(t, target)
+	ignore := goleak.IgnoreCurrent()
+
+	blocking := &blockingCollector{started: make(chan struct{}), release: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-blocking.started
+		cancel()
+	}()
+
+	ch := make(chan prometheus.Metric, 100)
+	err := c.collectTarget(ctx, ch, target, "", []collector.Collector{blocking})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want the scrape cancellation", err)
+	}
+
+	// The straggler finishes in the background once its BMC call returns
+	close(blocking.release)
+	c.Close()
+	bmc.Close()
+	goleak.VerifyNone(t, ignore)
+}
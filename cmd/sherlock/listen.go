@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// defaultListenAddress is used when no listen address is given
+const defaultListenAddress = "localhost:9290"
+
+// addressList is a repeatable flag of listen addresses
+type addressList []string
+
+// String returns the addresses as a comma-separated list
+func (a *addressList) String() string {
+	return strings.Join(*a, ",")
+}
+
+// Set adds a listen address
+func (a *addressList) Set(value string) error {
+	if value == "" {
+		return fmt.Errorf("listen address must not be empty")
+	}
+	*a = append(*a, value)
+	return nil
+}
+
+// servers are the HTTP servers serving the default handlers, one per listen address
+type servers []*http.Server
+
+// newServers creates a server for each listen address
+func newServers(addresses []string) servers {
+	s := make(servers, 0, len(addresses))
+	for _, address := range addresses {
+		s = append(s, &http.Server{Addr: address})
+	}
+	return s
+}
+
+// listenAndServe binds every address before serving any, so that the exporter doesn't start
+// on only some of them. It returns once a server fails or all of them are shut down.
+func (s servers) listenAndServe() error {
+	listeners := make([]net.Listener, 0, len(s))
+	for _, server := range s {
+		listener, err := net.Listen("tcp", server.Addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	errs := make(chan error, len(s))
+	for i, server := range s {
+		go func(server *http.Server, listener net.Listener) {
+			errs <- server.Serve(listener)
+		}(server, listeners[i])
+	}
+
+	for range s {
+		if err := <-errs; err != http.ErrServerClosed {
+			return err
+		}
+	}
+	return http.ErrServerClosed
+}
+
+// shutdown gracefully shuts down all servers concurrently, returning the first error
+func (s servers) shutdown(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make(chan error, len(s))
+	for _, server := range s {
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			errs <- server.Shutdown(ctx)
+		}(server)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close immediately closes all servers and their connections
+func (s servers) close() {
+	for _, server := range s {
+		server.Close()
+	}
+}
@@ -1,17 +1,24 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/mllnd/sherlock/internal/collector"
 	"github.com/mllnd/sherlock/internal/config"
+	"github.com/mllnd/sherlock/internal/ipmi"
 	"github.com/mllnd/sherlock/internal/logging"
 	"github.com/mllnd/sherlock/internal/redfish"
 	"github.com/prometheus/client_golang/prometheus"
@@ -25,45 +32,228 @@ var (
 )
 
 var (
-	listenAddress = flag.String("web.listen-address", "localhost:9290", "Address to listen on for web interface and telemetry")
-	metricsPath   = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics")
-	showVersion   = flag.Bool("version", false, "Print version information and exit")
+	metricsPath = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics")
+	configFile  = flag.String("config.file", "", "Path to the multi-target config file")
+	showVersion = flag.Bool("version", false, "Print version information and exit")
+	strict      = flag.Bool("strict", false, "Refuse to start with the built-in default Redfish credentials")
+	labels      = config.Labels{}
+
+	listenAddresses addressList
+
+	shutdownTimeout = flag.Duration("web.shutdown-timeout", 30*time.Second, "Maximum time to wait for in-flight scrapes when shutting down")
+	timeoutOffset   = flag.Duration("scrape.timeout-offset", 500*time.Millisecond, "Offset subtracted from the Prometheus scrape timeout so that partial results are returned before Prometheus gives up")
+	maxRequests     = flag.Int("web.max-requests", 0, "Maximum number of scrapes served concurrently, further scrapes are rejected with 503 (0 = no limit)")
+	queueTimeout    = flag.Duration("web.queue-timeout", 0, "Maximum time a scrape waits for one of the --web.max-requests slots, taking turns between targets, before it is rejected with 503 (0 = reject immediately)")
+
+	healthStateSet = flag.Bool("health.state-set", false, "Expose health statuses as state sets with one series per possible state")
+	healthNumeric  = flag.Bool("health.numeric", true, "Expose health statuses as numeric gauges")
+	healthNotAvail = flag.String("health.not-available", "", "Numeric health value of components without a reported status, e.g. NaN (default: 2 in the legacy scheme, 3 in the severity scheme)")
+	rawStates      = flag.Bool("metrics.raw-states", false, "Additionally expose the health and state strings of components as reported by the BMC in _status_info metrics")
+	healthScheme   = flag.String("health.scheme", collector.HealthSchemeLegacy, "Numeric health mapping: legacy (1 = OK, 0 = Warning/Critical, 2 = Not Available) or severity (0 = OK, 1 = Warning, 2 = Critical, 3 = Unknown)")
+
+	durationDecimals = flag.Int("metrics.scrape-duration-decimals", -1, "Round the per-collector scrape durations to this many decimal places of a second (negative = full precision)")
+	durationMillis   = flag.Bool("metrics.scrape-duration-milliseconds", false, "Additionally expose the per-collector scrape durations in milliseconds")
+
+	ipmiFallback = flag.Bool("ipmi.fallback", false, "Allow targets with ipmi_fallback set in the config file to be collected over IPMI-over-LAN (requires ipmitool)")
+
+	allTargetsEnabled     = flag.Bool("web.enable-all-targets", false, "Expose the metrics of every config file target, labeled by target, under <web.telemetry-path>/all")
+	allTargetsConcurrency = flag.Int("web.all-targets-concurrency", 4, "Maximum number of targets collected concurrently under <web.telemetry-path>/all")
+
+	runtimePath = flag.String("web.runtime-telemetry-path", "/exporter-metrics", "Path under which to expose the Go runtime and process metrics of the exporter itself, without collecting any target")
+
+	graphiteEnabled = flag.Bool("web.enable-graphite", false, "Expose metrics in the Graphite plaintext format under <web.telemetry-path>.graphite")
+
+	canaryTarget   = flag.String("ready.canary-target", "", "BMC whose Redfish service must be reachable for /ready to report ready (default: always ready)")
+	canaryInterval = flag.Duration("ready.canary-interval", 30*time.Second, "How long the result of a canary check is reused by /ready")
+
+	pushGatewayURL = flag.String("push.gateway-url", "", "Push the metrics of the config file targets to this Pushgateway every SCRAPE_INTERVAL instead of serving scrapes")
 )
 
+func init() {
+	flag.Var(labels, "label", "Static label applied to all metrics in key=value format (repeatable)")
+	flag.Var(&listenAddresses, "web.listen-address", "Address to listen on for web interface and telemetry, e.g. one per interface (repeatable, default: "+defaultListenAddress+")")
+}
+
 // SherlockCollector is the main collector that wraps all other collectors
 type SherlockCollector struct {
 	config  *config.Config
+	options collector.Options
 	clients map[string]*redfish.Client
+	history *scrapeHistory
 	mutex   sync.Mutex
 	logger  *logging.Logger
+
+	// maintenance holds the targets whose scrape errors are silenced
+	maintenance *maintenanceWindows
+
+	// canary caches the reachability of the canary target for readiness probes
+	canary canaryCheck
 }
 
 // NewSherlockCollector creates a new SherlockCollector
 func NewSherlockCollector(config *config.Config) (*SherlockCollector, error) {
+	options, err := collectorOptions(config)
+	if err != nil {
+		return nil, err
+	}
+
 	return &SherlockCollector{
 		config:  config,
+		options: options,
 		clients: make(map[string]*redfish.Client),
-		logger:  logging.New(),
+		history: newScrapeHistory(config.SuccessRatioWindow),
+		logger:  logging.New(""),
+
+		maintenance: newMaintenanceWindows(),
 	}, nil
 }
 
+// collectorOptions builds the shared collector options from the configuration
+func collectorOptions(cfg *config.Config) (collector.Options, error) {
+	if *healthScheme != collector.HealthSchemeLegacy && *healthScheme != collector.HealthSchemeSeverity {
+		return collector.Options{}, fmt.Errorf("invalid health scheme %q", *healthScheme)
+	}
+
+	var notAvailable *float64
+	if *healthNotAvail != "" {
+		value, err := strconv.ParseFloat(*healthNotAvail, 64)
+		if err != nil {
+			return collector.Options{}, fmt.Errorf("invalid not available health value %q", *healthNotAvail)
+		}
+		notAvailable = &value
+	}
+
+	options := collector.Options{
+		HealthStateSet:       *healthStateSet,
+		HealthScheme:         *healthScheme,
+		HealthNotAvailable:   notAvailable,
+		DisableHealthGauges:  !*healthNumeric,
+		RawStates:            *rawStates,
+		PSUSyntheticNames:    cfg.PSUSyntheticNames,
+		ODataIDLabel:         cfg.ODataIDLabel,
+		AllChassis:           cfg.AllChassis,
+		ChassisWorkers:       cfg.ChassisWorkers,
+		MaxLabelLength:       cfg.MaxLabelLength,
+		MaxSeries:            cfg.MaxSeries,
+		StaleScrapes:         cfg.StaleScrapes,
+		ScrapeInterval:       cfg.ScrapeInterval,
+		ChangesOnly:          cfg.ChangesOnly,
+		ChangeEpsilon:        cfg.ChangeEpsilon,
+		ChangeMaxAge:         cfg.ChangeMaxAge,
+		CriticalEventsWindow: cfg.CriticalEventsWindow,
+		EmptyRetries:         cfg.EmptyRetries,
+		EmptyRetryDelay:      cfg.EmptyRetryDelay,
+		Chassis:              cfg.Chassis,
+		MetricHelp:           cfg.MetricHelp(),
+		EmitZero:             make(map[string]bool),
+	}
+
+	options.ScrapeDurationDecimals = *durationDecimals
+	options.ScrapeDurationMilliseconds = *durationMillis
+
+	minTemperature, maxTemperature, err := config.ParseRange(cfg.TemperatureRange)
+	if err != nil {
+		return options, fmt.Errorf("invalid temperature range: %v", err)
+	}
+	options.TemperatureRange = collector.Range{Min: minTemperature, Max: maxTemperature}
+
+	minVoltage, maxVoltage, err := config.ParseRange(cfg.VoltageRange)
+	if err != nil {
+		return options, fmt.Errorf("invalid voltage range: %v", err)
+	}
+	options.VoltageRange = collector.Range{Min: minVoltage, Max: maxVoltage}
+
+	for _, name := range cfg.EmitZeroCollectors() {
+		options.EmitZero[name] = true
+	}
+
+	if cfg.SensorInclude != "" {
+		re, err := regexp.Compile(cfg.SensorInclude)
+		if err != nil {
+			return options, fmt.Errorf("invalid sensor include pattern: %v", err)
+		}
+		options.SensorInclude = re
+	}
+	if cfg.SensorExclude != "" {
+		re, err := regexp.Compile(cfg.SensorExclude)
+		if err != nil {
+			return options, fmt.Errorf("invalid sensor exclude pattern: %v", err)
+		}
+		options.SensorExclude = re
+	}
+
+	return options, nil
+}
+
+// canonicalTarget returns the identity used to key clients for the given target hostname.
+// When canonicalization is enabled, the host is resolved to its first IP address so that
+// the same BMC scraped by IP and by FQDN shares a single client and session.
+func (c *SherlockCollector) canonicalTarget(hostname string) string {
+	if !c.config.CanonicalizeTargets {
+		return hostname
+	}
+
+	host, port, err := net.SplitHostPort(hostname)
+	if err != nil {
+		host, port = hostname, ""
+	}
+
+	addrs, err := net.LookupHost(host)
+	if err != nil || len(addrs) == 0 {
+		c.logger.Debug("failed to resolve target, using it as is", "target", hostname, "error", err)
+		return hostname
+	}
+	sort.Strings(addrs)
+
+	if port != "" {
+		return net.JoinHostPort(addrs[0], port)
+	}
+	if strings.Contains(addrs[0], ":") {
+		return "[" + addrs[0] + "]"
+	}
+	return addrs[0]
+}
+
 // getClient returns a Redfish client for the given target hostname
 func (c *SherlockCollector) getClient(hostname string) (*redfish.Client, error) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	key := c.canonicalTarget(hostname)
 
 	// If we already have a client for this target, return it
-	if client, ok := c.clients[hostname]; ok {
+	c.mutex.Lock()
+	client, ok := c.clients[key]
+	c.mutex.Unlock()
+	if ok {
 		return client, nil
 	}
 
-	// Create a new client for this target
+	// Create a new client for this target without holding the lock, since connecting
+	// can take seconds and would block scrapes of every other target
 	targetURL := "https://" + hostname
+	username, password := c.config.CredentialsFor(hostname)
 	redfishConfig := redfish.Config{
 		Host:     targetURL,
-		Username: c.config.RedfishUsername,
-		Password: c.config.RedfishPassword,
+		Username: username,
+		Password: password,
 		Insecure: c.config.RedfishInsecure,
+		Timeout:  c.config.TimeoutFor(hostname),
+		Retry: redfish.RetryPolicy{
+			MaxRetries: c.config.MaxRetries,
+			MaxWait:    c.config.MaxRetryWait,
+		},
+		ConnectTimeout:  c.config.ConnectTimeout,
+		MaxRedirects:    c.config.MaxRedirects,
+		ReconnectJitter: c.config.ReconnectJitter,
+		MinTLSVersion:   c.config.TLSMinVersionFor(hostname),
+		CipherSuites:    c.config.TLSCipherSuiteIDs(),
+	}
+	if targetConfig, ok := c.config.Target(hostname); ok {
+		redfishConfig.PinAddress = targetConfig.PinAddress
+		redfishConfig.BasePath = targetConfig.BasePath
+		redfishConfig.ODataVersion = targetConfig.ODataVersion
+		redfishConfig.Accept = targetConfig.Accept
+	}
+	if c.config.AllChassis {
+		redfishConfig.MaxConcurrentRequests = int64(c.config.ChassisWorkers)
 	}
 
 	client, err := redfish.NewClient(redfishConfig)
@@ -71,21 +261,76 @@ func (c *SherlockCollector) getClient(hostname string) (*redfish.Client, error)
 		return nil, err
 	}
 
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Another scrape may have connected to the same target in the meantime
+	if existing, ok := c.clients[key]; ok {
+		client.Close()
+		return existing, nil
+	}
+
 	// Store the client for future use
-	c.clients[hostname] = client
+	c.clients[key] = client
 	return client, nil
 }
 
+// newCollectors creates a fresh set of all collectors
+func (c *SherlockCollector) newCollectors() []collector.Collector {
+	return append(c.newTargetCollectors(), c.newChassisCollectors()...)
+}
+
+// newTargetCollectors returns the collectors for target-wide resources (systems, managers)
+func (c *SherlockCollector) newTargetCollectors() []collector.Collector {
+	return []collector.Collector{
+		collector.NewSystemCollector(c.options),
+		collector.NewMemoryCollector(c.options),
+		collector.NewChassisCollector(c.options),
+		collector.NewManagerCollector(c.options),
+		collector.NewStorageCollector(c.options),
+		collector.NewCableCollector(c.options),
+		collector.NewEventCollector(c.options),
+	}
+}
+
+// newChassisCollectors returns the collectors that can be scoped to a single chassis
+func (c *SherlockCollector) newChassisCollectors() []collector.Collector {
+	return []collector.Collector{
+		collector.NewSensorCollector(c.options),
+		collector.NewPowerCollector(c.options),
+		collector.NewFansCollector(c.options),
+		collector.NewTelemetryCollector(c.options),
+	}
+}
+
+// subordinates returns the chassis behind a target configured as a Redfish aggregator.
+// It returns nil when the target is not an aggregator or exposes no AggregationService.
+func (c *SherlockCollector) subordinates(target string) []string {
+	if targetConfig, ok := c.config.Target(target); !ok || !targetConfig.Aggregator {
+		return nil
+	}
+
+	client, err := c.getClient(target)
+	if err != nil {
+		c.logTargetError(target, "failed to connect to redfish api", "error", err)
+		return nil
+	}
+
+	ids, ok, err := client.Subordinates()
+	if err != nil {
+		c.logTargetError(target, "failed to list aggregated chassis", "error", err)
+		return nil
+	}
+	if !ok {
+		c.logger.Debug("no aggregation service found, using single-system mode", "target", target)
+	}
+	return ids
+}
+
 // Describe implements the prometheus.Collector interface
 func (c *SherlockCollector) Describe(ch chan<- *prometheus.Desc) {
 	// Create temporary collectors to describe metrics
-	collectors := []collector.Collector{
-		collector.NewSystemCollector(),
-		collector.NewSensorCollector(),
-		collector.NewPowerCollector(),
-		collector.NewFansCollector(),
-		collector.NewTelemetryCollector(),
-	}
+	collectors := c.newCollectors()
 
 	for _, collector := range collectors {
 		collector.Describe(ch)
@@ -99,59 +344,218 @@ func (c *SherlockCollector) Collect(ch chan<- prometheus.Metric) {
 	c.logger.Warn("Collect method called without a target")
 }
 
-// collectTarget collects metrics for a specific target
-func (c *SherlockCollector) collectTarget(ch chan<- prometheus.Metric, target string) {
+// collectTarget collects metrics for a specific target and returns the error of the collection,
+// if any
+func (c *SherlockCollector) collectTarget(ctx context.Context, ch chan<- prometheus.Metric, target, chassisID string, collectors []collector.Collector) error {
+	collectors = c.enabledCollectors(target, collectors)
+
+	// Only the config file targets are known in advance, any other ?target= value would add a series
+	if _, ok := c.config.Target(target); ok {
+		targetConfigHash.WithLabelValues(target).Set(float64(c.config.Fingerprint(target)))
+	}
+
 	// Get or create a client for this target
 	client, err := c.getClient(target)
 	if err != nil {
-		c.logger.Error("failed to connect to redfish api", "target", target, "error", err)
-		return
+		if chassisID == "" && c.ipmiFallback(target) && redfish.IsUnavailable(err) {
+			c.logger.Debug("redfish unavailable, falling back to ipmi", "target", target, "error", err)
+			return c.collectIPMI(ctx, ch, target)
+		}
+		c.logTargetError(target, "failed to connect to redfish api", "error", err)
+		return err
 	}
 
-	// Create new collectors for this target
-	collectors := []collector.Collector{
-		collector.NewSystemCollector(),
-		collector.NewSensorCollector(),
-		collector.NewPowerCollector(),
-		collector.NewFansCollector(),
-		collector.NewTelemetryCollector(),
+	// Check the cached connection first, so collectors start with a healthy client
+	latency, err := client.Ping()
+	targetPingDuration.WithLabelValues(target).Set(latency.Seconds())
+	if err != nil {
+		c.logTargetError(target, "redfish connection check failed", "error", err)
+		return err
 	}
 
+	collectors, unsupported := c.supportedCollectors(client, target, collectors)
+
 	// Set target on each collector
+	identity := c.canonicalTarget(target)
 	for _, col := range collectors {
-		col.SetTarget(target)
+		col.SetTarget(identity)
+		col.SetChassis(chassisID)
+		col.SetContext(ctx)
 	}
 
-	// Create a wait group to wait for all collectors to finish
-	var wg sync.WaitGroup
-	wg.Add(len(collectors))
-
-	// Create a channel to collect errors
-	errChan := make(chan error, len(collectors))
-
-	// Update all collectors in parallel
+	// Update all collectors in parallel, each reporting when it's done
+	type update struct {
+		index int
+		err   error
+	}
+	updates := make(chan update, len(collectors))
 	for i := range collectors {
 		go func(index int) {
-			defer wg.Done()
-			if err := collectors[index].Update(client); err != nil {
-				errChan <- fmt.Errorf("error updating collector %T for target %s: %v", collectors[index], target, err)
+			var err error
+			if updateErr := collectors[index].Update(client); updateErr != nil {
+				err = fmt.Errorf("error updating collector %T for target %s: %w", collectors[index], target, updateErr)
 			}
+			updates <- update{index: index, err: err}
 		}(i)
 	}
 
-	// Wait for all collectors to finish
-	wg.Wait()
-	close(errChan)
+	// Wait for all collectors to finish, giving up once the scrape is cancelled or times out.
+	// The collectors done by then are still collected, stragglers finish in the background and
+	// their results are discarded.
+	finished := make([]bool, len(collectors))
+	var errs []error
+	var cancelled error
+wait:
+	for range collectors {
+		select {
+		case u := <-updates:
+			finished[u.index] = true
+			if u.err != nil {
+				errs = append(errs, u.err)
+			}
+		case <-ctx.Done():
+			cancelled = ctx.Err()
+			break wait
+		}
+	}
+	if cancelled != nil {
+		var stragglers []string
+		for i, col := range collectors {
+			if !finished[i] {
+				stragglers = append(stragglers, col.Name())
+			}
+		}
+		c.logger.Warn("scrape cancelled before all collectors finished, returning partial results",
+			"target", target,
+			"error", cancelled,
+			"unfinished", stragglers,
+		)
+	}
 
-	// Log any errors
-	for err := range errChan {
-		c.logger.Error("collector update failed", "error", err)
+	// Log any errors, keeping the first one as the scrape's last error
+	scrapeErr := cancelled
+	for _, err := range errs {
+		if collector.IsUnsupported(err) && c.config.UnsupportedCollectors == config.UnsupportedLenient {
+			c.logger.Debug("collector not supported by target", "target", target, "error", err)
+			continue
+		}
+		c.logTargetError(target, "collector update failed", "error", err)
+		if scrapeErr == nil {
+			scrapeErr = err
+		}
+	}
+	if len(unsupported) > 0 && c.config.UnsupportedCollectors == config.UnsupportedStrict {
+		err := fmt.Errorf("collectors %s %w", strings.Join(unsupported, ", "), collector.ErrUnsupported)
+		c.logTargetError(target, "collector update failed", "error", err)
+		if scrapeErr == nil {
+			scrapeErr = err
+		}
 	}
 
-	// Collect metrics from all collectors
-	for _, collector := range collectors {
-		collector.Collect(ch)
+	// Collect metrics from all collectors that finished
+	for i, collector := range collectors {
+		if finished[i] {
+			collector.Collect(ch)
+		}
+	}
+
+	return scrapeErr
+}
+
+// enabledCollectors returns the collectors enabled for the target by its group
+func (c *SherlockCollector) enabledCollectors(target string, collectors []collector.Collector) []collector.Collector {
+	enabled := c.config.CollectorsFor(target)
+	if enabled == nil {
+		return collectors
+	}
+
+	var filtered []collector.Collector
+	for _, col := range collectors {
+		if enabled[col.Name()] {
+			filtered = append(filtered, col)
+		}
+	}
+	return filtered
+}
+
+// collectorResources are the service root resources each collector reads, one of which must be
+// linked for the collector to run
+var collectorResources = map[string][]string{
+	"system":    {"Systems"},
+	"memory":    {"Systems"},
+	"storage":   {"Systems"},
+	"event":     {"Systems"},
+	"bmc":       {"Managers"},
+	"chassis":   {"Chassis"},
+	"cable":     {"Chassis"},
+	"sensor":    {"Chassis"},
+	"fan":       {"Chassis"},
+	"telemetry": {"Chassis"},
+	"power":     {"Chassis", "PowerEquipment"},
+}
+
+// supportedCollectors splits the collectors into those whose resources the service root of the
+// target links and the names of the others, so that minimal BMCs aren't sent requests for
+// resources they don't have
+func (c *SherlockCollector) supportedCollectors(client *redfish.Client, target string, collectors []collector.Collector) ([]collector.Collector, []string) {
+	capabilities, err := client.Capabilities()
+	if err != nil {
+		c.logger.Debug("failed to read service root capabilities", "target", target, "error", err)
+		return collectors, nil
+	}
+
+	var supported []collector.Collector
+	var unsupported []string
+	for _, col := range collectors {
+		resources, ok := collectorResources[col.Name()]
+		if !ok {
+			supported = append(supported, col)
+			continue
+		}
+
+		linked := false
+		for _, resource := range resources {
+			linked = linked || capabilities[resource]
+		}
+		if linked {
+			supported = append(supported, col)
+		} else {
+			unsupported = append(unsupported, col.Name())
+		}
+	}
+	if len(unsupported) > 0 {
+		c.logger.Debug("skipping collectors not supported by the service root", "target", target, "collectors", unsupported)
+	}
+	return supported, unsupported
+}
+
+// ipmiFallback reports whether a target may be collected over IPMI when it has no Redfish service
+func (c *SherlockCollector) ipmiFallback(target string) bool {
+	targetConfig, ok := c.config.Target(target)
+	return *ipmiFallback && ok && targetConfig.IPMIFallback
+}
+
+// collectIPMI collects the basic sensor and power metrics of a target over IPMI-over-LAN and
+// returns the error of the collection, if any
+func (c *SherlockCollector) collectIPMI(ctx context.Context, ch chan<- prometheus.Metric, target string) error {
+	username, password := c.config.CredentialsFor(target)
+	client := &ipmi.Client{
+		Host:     target,
+		Username: username,
+		Password: password,
+		Timeout:  c.config.TimeoutFor(target),
+	}
+
+	ipmiCollector := collector.NewIPMICollector(c.options)
+	ipmiCollector.SetTarget(c.canonicalTarget(target))
+
+	if err := ipmiCollector.Update(ctx, client); err != nil {
+		c.logTargetError(target, "ipmi collection failed", "error", err)
+		return err
 	}
+
+	ipmiCollector.Collect(ch)
+	return nil
 }
 
 // Close closes all Redfish clients
@@ -167,16 +571,39 @@ func (c *SherlockCollector) Close() {
 
 func main() {
 	flag.Parse()
+	if len(listenAddresses) == 0 {
+		listenAddresses = addressList{defaultListenAddress}
+	}
 
 	if *showVersion {
 		fmt.Printf("sherlock %s-%s\n", version, commit)
 		os.Exit(0)
 	}
 
-	logger := logging.New()
+	logger := logging.New("")
 
 	// Load configuration
 	cfg := config.NewConfig()
+	if *configFile != "" {
+		if err := cfg.LoadFile(*configFile); err != nil {
+			logger.Error("failed to load config file", "error", err)
+			os.Exit(1)
+		}
+	}
+	cfg.Labels = labels
+	cfg.Strict = *strict
+	if err := cfg.Validate(); err != nil {
+		logger.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+	if cfg.DefaultCredentials() {
+		logger.Warn("using the built-in default redfish credentials",
+			"hint", "set REDFISH_USERNAME and REDFISH_PASSWORD, or use --strict to refuse starting",
+		)
+	}
+	cfg.LogEffective(logger)
+
+	redfish.SetMaxConcurrentReconnects(cfg.MaxConcurrentReconnects)
 
 	// Create collector
 	collector, err := NewSherlockCollector(cfg)
@@ -186,26 +613,85 @@ func main() {
 	}
 	defer collector.Close()
 
-	// Create a custom handler for metrics that supports the target parameter
-	http.HandleFunc(*metricsPath, func(w http.ResponseWriter, r *http.Request) {
-		target := r.URL.Query().Get("target")
+	// Make sure the static and group labels don't collide with any metric labels
+	labelSets := []config.Labels{cfg.Labels}
+	checkedGroups := make(map[string]bool)
+	for _, target := range cfg.Targets {
+		if target.Group != "" && !checkedGroups[target.Group] {
+			checkedGroups[target.Group] = true
+			labelSets = append(labelSets, cfg.LabelsFor(target.Host))
+		}
+	}
+	for _, labelSet := range labelSets {
+		checkRegisterer := prometheus.WrapRegistererWith(prometheus.Labels(labelSet), prometheus.NewRegistry())
+		for _, c := range append([]prometheus.Collector{collector}, exporterMetrics()...) {
+			if err := checkRegisterer.Register(c); err != nil {
+				logger.Error("static labels collide with metric labels", "error", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Register the exporter metrics once, to be gathered with every scrape
+	if err := registerExporterMetrics(cfg.Labels); err != nil {
+		logger.Error("failed to register exporter metrics", "error", err)
+		os.Exit(1)
+	}
+	configuredScrapeInterval.Set(cfg.ScrapeInterval.Seconds())
+
+	// Make sure no two collectors expose the same metric
+	if err := checkDuplicateMetrics(collector.newCollectors()); err != nil {
+		logger.Error("duplicate metrics", "error", err)
+		os.Exit(1)
+	}
+
+	// Push the metrics of the configured targets instead of serving scrapes if requested
+	if *pushGatewayURL != "" {
+		if len(cfg.Targets) == 0 {
+			logger.Error("push mode requires targets in the config file")
+			os.Exit(1)
+		}
+		if cfg.ScrapeInterval <= 0 {
+			logger.Error("push mode requires a positive SCRAPE_INTERVAL")
+			os.Exit(1)
+		}
+		if cfg.ChangesOnly {
+			logger.Error("EMIT_CHANGES_ONLY is not supported in push mode, the Pushgateway rejects timestamped metrics")
+			os.Exit(1)
+		}
 
-		if target == "" {
-			http.Error(w, "Error: 'target' parameter is required (e.g. ?target=bmc.example.com)", http.StatusBadRequest)
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		logger.Info("starting sherlock redfish exporter in push mode",
+			"gateway", *pushGatewayURL,
+			"interval", cfg.ScrapeInterval,
+			"targets", len(cfg.Targets),
+		)
+		collector.runPush(ctx, *pushGatewayURL)
+		logger.Info("shutting down...")
+		return
+	}
+
+	// Create a custom handler for metrics that supports the target parameter
+	http.HandleFunc(*metricsPath, limitRequests(func(w http.ResponseWriter, r *http.Request) {
+		target, ok := collector.targetParam(w, r)
+		if !ok {
 			return
 		}
 
-		// Remove any protocol prefix if accidentally included
-		target = strings.TrimPrefix(target, "http://")
-		target = strings.TrimPrefix(target, "https://")
+		done := scrapes.start(target)
+		defer done()
 
 		logger.Debug("starting metrics collection",
 			"target", target,
 			"goroutine", fmt.Sprintf("%p", &target),
 		)
 
-		registry := prometheus.NewRegistry()
-		registry.MustRegister(&targetCollector{collector: collector, target: target})
+		ctx, cancel := scrapeContext(r)
+		defer cancel()
+
+		registry := collector.targetGatherer(ctx, target, r.URL.Query().Get("chassis"))
 
 		h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
 		h.ServeHTTP(w, r)
@@ -214,7 +700,29 @@ func main() {
 			"target", target,
 			"goroutine", fmt.Sprintf("%p", &target),
 		)
-	})
+	}))
+
+	// Expose the same metrics in the Graphite plaintext format if requested
+	if *graphiteEnabled {
+		http.HandleFunc(*metricsPath+".graphite", limitRequests(collector.graphiteHandler))
+	}
+
+	// Collect every config file target on one endpoint if requested
+	if *allTargetsEnabled {
+		http.HandleFunc(*metricsPath+"/all", limitRequests(collector.allTargetsHandler))
+	}
+
+	// Expose the raw Redfish debug and maintenance endpoints only when admin credentials are configured
+	if cfg.AdminEnabled() {
+		http.HandleFunc("/debug/redfish", requireAuth(cfg, collector.debugRedfishHandler))
+		http.HandleFunc("/admin/maintenance", requireAuth(cfg, collector.maintenanceHandler))
+	}
+
+	// Expose the exporter's own runtime and process metrics, together with the exporter metrics
+	http.Handle(*runtimePath, promhttp.HandlerFor(prometheus.Gatherers{runtimeRegistry, exporterRegistry}, promhttp.HandlerOpts{}))
+
+	// Report readiness, gated by the canary target if configured
+	http.HandleFunc("/ready", collector.readyHandler)
 
 	// Create index page
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -224,39 +732,219 @@ func main() {
 			<h1>Sherlock Redfish Exporter</h1>
 			<p>This exporter requires a target parameter (hostname only):</p>
 			<p><a href="` + *metricsPath + `?target=bmc.example.com">` + *metricsPath + `?target=bmc.example.com</a></p>
+			<p>The metrics of the exporter itself are under <a href="` + *runtimePath + `">` + *runtimePath + `</a></p>
 			</body>
 			</html>`))
 	})
 
 	// Start HTTP server
-	logger.Info("starting sherlock redfish exporter", "address", *listenAddress)
+	logger.Info("starting sherlock redfish exporter", "addresses", []string(listenAddresses))
+
+	servers := newServers(listenAddresses)
+
+	// Keep BMC sessions alive between scrapes if requested
+	keepAliveCtx, stopKeepAlive := context.WithCancel(context.Background())
+	keepAliveDone := make(chan struct{})
+	go func() {
+		defer close(keepAliveDone)
+		if cfg.KeepAliveInterval > 0 {
+			collector.keepAlive(keepAliveCtx, cfg.KeepAliveInterval)
+		}
+	}()
 
-	// Handle graceful shutdown
+	// Handle graceful shutdown, waiting for in-flight scrapes up to the shutdown timeout
+	shutdownDone := make(chan struct{})
 	go func() {
 		signals := make(chan os.Signal, 1)
 		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 		<-signals
-		logger.Info("shutting down...")
+		logger.Info("shutting down...", "in_flight_targets", scrapes.active())
+
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := servers.shutdown(ctx); err != nil {
+			logger.Warn("shutdown timed out, closing in-flight scrapes", "targets", scrapes.active())
+			servers.close()
+
+			// Closing the servers cancels the scrapes but doesn't wait for them, give them the
+			// shutdown timeout again to return before their sessions are logged out
+			cancel()
+			ctx, cancel = context.WithTimeout(context.Background(), *shutdownTimeout)
+			defer cancel()
+		}
+		if err := scrapes.wait(ctx); err != nil {
+			logger.Warn("scrapes still in flight, closing their sessions", "targets", scrapes.active())
+		}
+
+		// Stop pinging before closing the sessions so that none is reopened
+		stopKeepAlive()
+		<-keepAliveDone
+
 		collector.Close()
-		os.Exit(0)
+		close(shutdownDone)
 	}()
 
-	if err := http.ListenAndServe(*listenAddress, nil); err != nil {
+	if err := servers.listenAndServe(); err != http.ErrServerClosed {
 		logger.Error("http server failed", "error", err)
 		os.Exit(1)
 	}
+	<-shutdownDone
 }
 
-// targetCollector is a wrapper around SherlockCollector that collects metrics for a specific target
-type targetCollector struct {
+// targetParam returns the validated target of a scrape request, writing an error response if it is invalid
+func (c *SherlockCollector) targetParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	target := normalizeTarget(r.URL.Query().Get("target"))
+
+	if target == "" {
+		http.Error(w, "Error: 'target' parameter is required (e.g. ?target=bmc.example.com)", http.StatusBadRequest)
+		return "", false
+	}
+
+	for _, address := range listenAddresses {
+		if isSelfTarget(target, address) {
+			http.Error(w, "Error: 'target' must not point at the exporter itself", http.StatusBadRequest)
+			return "", false
+		}
+	}
+
+	if !c.config.TargetAllowed(target) {
+		http.Error(w, "Error: 'target' is not in the target allowlist", http.StatusForbidden)
+		return "", false
+	}
+
+	return target, true
+}
+
+// scrapeContext returns the context of a scrape request, bounded by the Prometheus scrape timeout
+// minus the configured offset if sent
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64); err == nil && seconds > 0 {
+		timeout := time.Duration(seconds * float64(time.Second))
+		// Keep the full timeout if the offset would leave no time at all
+		if timeout > *timeoutOffset {
+			timeout -= *timeoutOffset
+		}
+		return context.WithTimeout(r.Context(), timeout)
+	}
+	return context.WithCancel(r.Context())
+}
+
+// targetRegistry builds the per-scrape registry collecting a target, optionally scoped to a chassis
+func (c *SherlockCollector) targetRegistry(ctx context.Context, target, chassisID string) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels(c.config.LabelsFor(target)), registry)
+	c.registerTarget(ctx, registerer, target, chassisID, nil)
+
+	return registry
+}
+
+// targetGatherer merges the per-scrape registry of a target with its exporter metrics. The
+// target is gathered first, so that the exporter metrics reflect this scrape.
+func (c *SherlockCollector) targetGatherer(ctx context.Context, target, chassisID string) prometheus.Gatherer {
+	return prometheus.Gatherers{c.targetRegistry(ctx, target, chassisID), c.exporterMetricsOf(target)}
+}
+
+// registerTarget registers the collectors of a target, optionally scoped to a chassis. If slots is
+// set, each collection of the target holds one of them while it runs.
+func (c *SherlockCollector) registerTarget(ctx context.Context, registerer prometheus.Registerer, target, chassisID string, slots chan struct{}) {
+	// Collect every chassis behind an aggregator separately, labeled by its ID
+	if subordinates := c.subordinates(target); len(subordinates) > 0 && chassisID == "" {
+		scrape := &targetScrape{collector: c, target: target, pending: len(subordinates) + 1}
+		registerer.MustRegister(&targetCollector{
+			ctx:           ctx,
+			collector:     c,
+			target:        target,
+			newCollectors: c.newTargetCollectors,
+			slots:         slots,
+			scrape:        scrape,
+		})
+		for _, id := range subordinates {
+			prometheus.WrapRegistererWith(prometheus.Labels{"subordinate": id}, registerer).MustRegister(&targetCollector{
+				ctx:           ctx,
+				collector:     c,
+				target:        target,
+				chassisID:     id,
+				newCollectors: c.newChassisCollectors,
+				slots:         slots,
+				scrape:        scrape,
+			})
+		}
+	} else {
+		registerer.MustRegister(&targetCollector{
+			ctx:           ctx,
+			collector:     c,
+			target:        target,
+			chassisID:     chassisID,
+			newCollectors: c.newCollectors,
+			slots:         slots,
+			scrape:        &targetScrape{collector: c, target: target, pending: 1},
+		})
+	}
+}
+
+// targetScrape records the result of one scrape of a target once all of its collections are
+// done, so that an aggregator and its subordinates count as a single scrape that failed if any
+// of them did
+type targetScrape struct {
 	collector *SherlockCollector
 	target    string
+
+	mutex   sync.Mutex
+	pending int
+	err     error
+}
+
+// done records the result of one collection of the scrape
+func (s *targetScrape) done(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.err == nil {
+		s.err = err
+	}
+	s.pending--
+	if s.pending == 0 {
+		s.collector.recordScrapeResult(s.target, s.err)
+	}
+}
+
+// normalizeTarget removes any protocol prefix accidentally included in a target
+func normalizeTarget(target string) string {
+	target = strings.TrimPrefix(target, "http://")
+	return strings.TrimPrefix(target, "https://")
+}
+
+// targetCollector is a wrapper around SherlockCollector that collects metrics for a specific target
+type targetCollector struct {
+	ctx           context.Context
+	collector     *SherlockCollector
+	target        string
+	chassisID     string
+	newCollectors func() []collector.Collector
+
+	// slots bounds the targets collected concurrently when set
+	slots chan struct{}
+
+	// scrape records the result of the scrape the collection is part of
+	scrape *targetScrape
 }
 
 func (tc *targetCollector) Describe(ch chan<- *prometheus.Desc) {
-	tc.collector.Describe(ch)
+	for _, col := range tc.newCollectors() {
+		col.Describe(ch)
+	}
 }
 
 func (tc *targetCollector) Collect(ch chan<- prometheus.Metric) {
-	tc.collector.collectTarget(ch, tc.target)
+	if tc.slots != nil {
+		select {
+		case tc.slots <- struct{}{}:
+			defer func() { <-tc.slots }()
+		case <-tc.ctx.Done():
+			tc.scrape.done(tc.ctx.Err())
+			return
+		}
+	}
+
+	tc.scrape.done(tc.collector.collectTarget(tc.ctx, ch, tc.target, tc.chassisID, tc.newCollectors()))
 }
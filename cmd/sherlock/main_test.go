@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mllnd/sherlock/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// registerOnce registers the exporter metrics for all tests of the package
+var registerOnce sync.Once
+
+// newTestCollector creates a collector for the given config file targets with the exporter
+// metrics registered
+func newTestCollector(t testing.TB, targets ...string) *SherlockCollector {
+	t.Helper()
+
+	registerOnce.Do(func() {
+		if err := registerExporterMetrics(nil); err != nil {
+			t.Fatalf("failed to register exporter metrics: %v", err)
+		}
+	})
+
+	cfg := config.NewConfig()
+	for _, target := range targets {
+		cfg.Targets = append(cfg.Targets, config.TargetConfig{Host: target})
+	}
+
+	c, err := NewSherlockCollector(cfg)
+	if err != nil {
+		t.Fatalf("failed to create collector: %v", err)
+	}
+	t.Cleanup(c.Close)
+	return c
+}
+
+// newFakeBMC starts a Redfish service that only serves its service root and sessions. If wait is
+// set, it's called before serving the service root.
+func newFakeBMC(wait func()) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodPost {
+			w.Header().Set("X-Auth-Token", "token")
+			w.Header().Set("Location", "/redfish/v1/SessionService/Sessions/1")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"@odata.id": "/redfish/v1/SessionService/Sessions/1", "Id": "1"}`))
+			return
+		}
+		if wait != nil {
+			wait()
+		}
+		w.Write([]byte(`{
+			"@odata.id": "/redfish/v1/",
+			"Id": "RootService",
+			"Links": {"Sessions": {"@odata.id": "/redfish/v1/SessionService/Sessions"}}
+		}`))
+	}))
+}
+
+// gaugeValue returns the value of a gauge
+func gaugeValue(t *testing.T, gauge prometheus.Gauge) float64 {
+	t.Helper()
+
+	var m dto.Metric
+	if err := gauge.Write(&m); err != nil {
+		t.Fatalf("failed to read gauge: %v", err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func TestTargetScrapeRecordsOneResult(t *testing.T) {
+	const target = "127.0.0.1:1"
+	c := newTestCollector(t, target)
+	defer targetLastError.Reset()
+	defer targetSuccessRatio.Reset()
+
+	// An aggregator scraped with two subordinates, one of which fails
+	scrape := &targetScrape{collector: c, target: target, pending: 3}
+	scrape.done(nil)
+	scrape.done(errors.New("subordinate timed out"))
+	if c.history.targets[target] != nil {
+		t.Fatal("the result was recorded before all collections were done")
+	}
+	scrape.done(nil)
+
+	if ratio := gaugeValue(t, targetSuccessRatio.WithLabelValues(target)); ratio != 0 {
+		t.Errorf("got success ratio %v, want 0 for a single failed scrape", ratio)
+	}
+	if n := len(c.history.targets[target].results); n != 1 {
+		t.Errorf("recorded %d results, want 1", n)
+	}
+	if got := gaugeValue(t, targetLastError.WithLabelValues(target, "other")); got != 1 {
+		t.Error("the failed subordinate isn't the last error of the target")
+	}
+}
+
+func TestTargetGathererExposesThisScrapeOfTheTarget(t *testing.T) {
+	// Nothing listens on port 1, so the scrape fails and records the target's last error
+	const target = "127.0.0.1:1"
+	c := newTestCollector(t, target)
+	targetLastError.WithLabelValues("127.0.0.1:2", "timeout").Set(1)
+	defer targetLastError.Reset()
+
+	families, err := c.targetGatherer(context.Background(), target, "").Gather()
+	if err != nil {
+		t.Fatalf("failed to gather: %v", err)
+	}
+
+	var targets []string
+	for _, family := range families {
+		if family.GetName() != "sherlock_target_last_error" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			value, _ := targetLabel(m)
+			targets = append(targets, value)
+		}
+	}
+	if len(targets) != 1 || targets[0] != target {
+		t.Errorf("got the last errors of %v, want only this scrape's of %s", targets, target)
+	}
+}
+
+func TestConfigHashOnlyForConfigFileTargets(t *testing.T) {
+	const listed, unlisted = "127.0.0.1:1", "127.0.0.1:2"
+	c := newTestCollector(t, listed)
+	targetConfigHash.Reset()
+	defer targetConfigHash.Reset()
+
+	for _, target := range []string{listed, unlisted} {
+		if _, err := c.targetGatherer(context.Background(), target, "").Gather(); err != nil {
+			t.Fatalf("failed to gather %s: %v", target, err)
+		}
+	}
+
+	if !targetConfigHash.DeleteLabelValues(listed) {
+		t.Errorf("no config hash for the config file target %s", listed)
+	}
+	if targetConfigHash.DeleteLabelValues(unlisted) {
+		t.Errorf("config hash for the unlisted target %s", unlisted)
+	}
+}
+
+func BenchmarkGetClientWhileConnecting(b *testing.B) {
+	// A target that doesn't answer until the benchmark is done
+	connecting, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	slow := newFakeBMC(func() {
+		once.Do(func() { close(connecting) })
+		<-release
+	})
+	defer slow.Close()
+	defer close(release)
+
+	connected := newFakeBMC(nil)
+	defer connected.Close()
+
+	target, slowTarget := strings.TrimPrefix(connected.URL, "https://"), strings.TrimPrefix(slow.URL, "https://")
+	c := newTestCollector(b, target, slowTarget)
+	if _, err := c.getClient(target); err != nil {
+		b.Fatalf("failed to connect: %v", err)
+	}
+
+	// Look up the connected target while connecting to the slow one
+	go c.getClient(slowTarget)
+	<-connecting
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := c.getClient(target); err != nil {
+				b.Errorf("failed to get client: %v", err)
+			}
+		}
+	})
+}
+
+func TestIdleTargetSeriesAreDeleted(t *testing.T) {
+	const idle, active = "127.0.0.1:1", "127.0.0.1:2"
+	c := newTestCollector(t)
+	defer targetSuccessRatio.Reset()
+	defer targetPingDuration.Reset()
+	defer targetMaintenance.Reset()
+
+	original := targetIdleTimeout
+	defer func() { targetIdleTimeout = original }()
+	targetIdleTimeout = 0
+
+	targetPingDuration.WithLabelValues(idle).Set(0.1)
+	c.recordScrapeResult(idle, nil)
+
+	// With any idle time being too long, the next scrape of another target drops the first one
+	c.recordScrapeResult(active, nil)
+
+	for _, vec := range []*prometheus.GaugeVec{targetSuccessRatio, targetPingDuration, targetMaintenance} {
+		if vec.DeleteLabelValues(idle) {
+			t.Errorf("the idle target %s kept a series", idle)
+		}
+	}
+	if c.history.targets[idle] != nil {
+		t.Errorf("the idle target %s kept its history", idle)
+	}
+	if !targetSuccessRatio.DeleteLabelValues(active) {
+		t.Errorf("no success ratio for the scraped target %s", active)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maintenanceWindows tracks the targets under planned maintenance and when their windows end
+type maintenanceWindows struct {
+	mutex sync.Mutex
+	until map[string]time.Time
+}
+
+// newMaintenanceWindows creates an empty set of maintenance windows
+func newMaintenanceWindows() *maintenanceWindows {
+	return &maintenanceWindows{until: make(map[string]time.Time)}
+}
+
+// set puts a target under maintenance until the given time
+func (m *maintenanceWindows) set(target string, until time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.until[target] = until
+}
+
+// clear ends the maintenance window of a target
+func (m *maintenanceWindows) clear(target string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.until, target)
+}
+
+// active reports whether a target is under maintenance, forgetting its window once expired
+func (m *maintenanceWindows) active(target string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	until, ok := m.until[target]
+	if ok && time.Now().After(until) {
+		delete(m.until, target)
+		return false
+	}
+	return ok
+}
+
+// logTargetError logs a failure of a target as an error, or only at debug level while the
+// target is under maintenance
+func (c *SherlockCollector) logTargetError(target, msg string, fields ...interface{}) {
+	fields = append([]interface{}{"target", target}, fields...)
+	if c.maintenance.active(target) {
+		c.logger.Debug(msg, append(fields, "maintenance", true)...)
+		return
+	}
+	c.logger.Error(msg, fields...)
+}
+
+// maintenanceHandler starts (POST) or ends (DELETE) the maintenance window of a target
+func (c *SherlockCollector) maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	target := normalizeTarget(r.URL.Query().Get("target"))
+	if target == "" {
+		http.Error(w, "Error: 'target' parameter is required (e.g. ?target=bmc.example.com)", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+		if err != nil || duration <= 0 {
+			http.Error(w, "Error: 'duration' parameter must be a positive duration (e.g. ?duration=2h)", http.StatusBadRequest)
+			return
+		}
+		until := time.Now().Add(duration)
+		c.maintenance.set(target, until)
+		c.logger.Info("maintenance window started", "target", target, "until", until)
+	case http.MethodDelete:
+		c.maintenance.clear(target)
+		c.logger.Info("maintenance window ended", "target", target)
+	default:
+		w.Header().Set("Allow", http.MethodPost+", "+http.MethodDelete)
+		http.Error(w, "Error: only POST and DELETE are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mllnd/sherlock/internal/collector"
+	"github.com/mllnd/sherlock/internal/config"
+	"github.com/mllnd/sherlock/internal/redfish"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// targetLastError exposes the category of the last failed scrape of each target
+var targetLastError = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "sherlock_target_last_error",
+		Help: "Category of the last scrape error of the target (auth, timeout, unreachable, parse, other), always 1",
+	},
+	[]string{"target", "error"},
+)
+
+// targetPingDuration exposes how long the connection check before each scrape took
+var targetPingDuration = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "sherlock_target_ping_duration_seconds",
+		Help: "Duration of the Redfish service root check before the last scrape of the target, including any reconnection",
+	},
+	[]string{"target"},
+)
+
+// targetSuccessRatio exposes the share of successful scrapes of each target over the history window
+var targetSuccessRatio = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "sherlock_target_scrape_success_ratio",
+		Help: "Ratio of successful scrapes of the target over the last SUCCESS_RATIO_WINDOW scrapes",
+	},
+	[]string{"target"},
+)
+
+// targetMaintenance exposes whether each target is under planned maintenance
+var targetMaintenance = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "sherlock_target_maintenance",
+		Help: "Whether the target is in a maintenance window, during which its scrape errors are not reported (1 = yes, 0 = no)",
+	},
+	[]string{"target"},
+)
+
+// targetConfigHash exposes a fingerprint of the resolved settings of each target
+var targetConfigHash = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "sherlock_target_config_hash",
+		Help: "Hash of the resolved settings of the target (collectors, timeout, labels and connection settings, excluding credentials)",
+	},
+	[]string{"target"},
+)
+
+// scrapesInFlight exposes the number of scrapes currently being served
+var scrapesInFlight = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "sherlock_scrapes_in_flight",
+		Help: "Number of scrapes currently being served",
+	},
+)
+
+// scrapeQueueDepth exposes the number of scrapes waiting for a free slot per target
+var scrapeQueueDepth = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "sherlock_scrape_queue_depth",
+		Help: "Number of scrapes of the target waiting for one of the --web.max-requests slots",
+	},
+	[]string{"target"},
+)
+
+// exporterRegistry holds the metrics about the exporter itself. They are registered once and
+// gathered together with the per-scrape registry of the target on every scrape.
+var exporterRegistry = prometheus.NewRegistry()
+
+// targetsGatherer gathers the exporter metrics of some targets, dropping the series labeled with
+// any other target so that a scrape doesn't repeat those of every target ever scraped. Series
+// without a target label are kept.
+type targetsGatherer struct {
+	prometheus.Gatherer
+	targets map[string]bool
+}
+
+// exporterMetricsOf returns the exporter metrics of the given targets, under their own name and
+// their canonical identity
+func (c *SherlockCollector) exporterMetricsOf(targets ...string) prometheus.Gatherer {
+	g := targetsGatherer{Gatherer: exporterRegistry, targets: make(map[string]bool)}
+	for _, target := range targets {
+		g.targets[target] = true
+		g.targets[c.canonicalTarget(target)] = true
+	}
+	return g
+}
+
+// Gather gathers the exporter metrics, keeping only the series of the targets
+func (g targetsGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+
+	filtered := families[:0]
+	for _, family := range families {
+		metrics := family.Metric[:0]
+		for _, m := range family.Metric {
+			if target, ok := targetLabel(m); !ok || g.targets[target] {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) > 0 {
+			family.Metric = metrics
+			filtered = append(filtered, family)
+		}
+	}
+	return filtered, err
+}
+
+// targetLabel returns the value of the target label of a metric, false if it has none
+func targetLabel(m *dto.Metric) (string, bool) {
+	for _, label := range m.GetLabel() {
+		if label.GetName() == "target" {
+			return label.GetValue(), true
+		}
+	}
+	return "", false
+}
+
+// configuredScrapeInterval exposes the configured SCRAPE_INTERVAL
+var configuredScrapeInterval = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "sherlock_configured_scrape_interval_seconds",
+		Help: "Configured interval between collections of the config file targets (SCRAPE_INTERVAL)",
+	},
+)
+
+// runtimeRegistry holds the Go runtime and process metrics of the exporter. They are only exposed
+// under --web.runtime-telemetry-path, since they would be repeated with every target otherwise.
+var runtimeRegistry = prometheus.NewRegistry()
+
+// registerExporterMetrics registers the exporter metrics on the shared registry and the runtime
+// metrics on their own, both with the static labels
+func registerExporterMetrics(labels config.Labels) error {
+	registerer := prometheus.WrapRegistererWith(prometheus.Labels(labels), exporterRegistry)
+	for _, metric := range exporterMetrics() {
+		if err := registerer.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	registerer = prometheus.WrapRegistererWith(prometheus.Labels(labels), runtimeRegistry)
+	for _, metric := range runtimeMetrics() {
+		if err := registerer.Register(metric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runtimeMetrics returns the collectors of the Go runtime and process metrics of the exporter
+func runtimeMetrics() []prometheus.Collector {
+	return []prometheus.Collector{
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	}
+}
+
+// exporterMetrics returns the metrics about the exporter itself, exposed with every target
+func exporterMetrics() []prometheus.Collector {
+	return []prometheus.Collector{
+		redfish.RateLimitedTotal,
+		redfish.ChassisRetrievalErrorsTotal,
+		collector.SeriesDroppedTotal,
+		targetLastError,
+		targetPingDuration,
+		targetSuccessRatio,
+		targetMaintenance,
+		targetConfigHash,
+		scrapesInFlight,
+		scrapeQueueDepth,
+		configuredScrapeInterval,
+	}
+}
+
+// recordScrapeResult sets the last error of a target, clearing it when the scrape succeeded,
+// and updates its success ratio. Results of targets under maintenance are not recorded. The
+// series of targets that haven't been scraped for a while are deleted.
+func (c *SherlockCollector) recordScrapeResult(target string, err error) {
+	for _, idle := range c.history.touch(target, time.Now()) {
+		deleteTargetSeries(idle)
+	}
+	targetLastError.DeletePartialMatch(prometheus.Labels{"target": target})
+
+	if c.maintenance.active(target) {
+		targetMaintenance.WithLabelValues(target).Set(1)
+		return
+	}
+	targetMaintenance.WithLabelValues(target).Set(0)
+
+	if err != nil {
+		targetLastError.WithLabelValues(target, redfish.ErrorCategory(err)).Set(1)
+	}
+
+	targetSuccessRatio.WithLabelValues(target).Set(c.history.record(target, err == nil))
+}
+
+// deleteTargetSeries deletes the exporter metrics of a target that is no longer scraped
+func deleteTargetSeries(target string) {
+	targetLastError.DeletePartialMatch(prometheus.Labels{"target": target})
+	targetPingDuration.DeleteLabelValues(target)
+	targetSuccessRatio.DeleteLabelValues(target)
+	targetMaintenance.DeleteLabelValues(target)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// Pushgateway jobs the metrics are pushed under: the metrics of each target, grouped by target,
+// and the exporter metrics, which carry a target label themselves and are pushed ungrouped
+const (
+	pushJob         = "sherlock"
+	pushExporterJob = "sherlock_exporter"
+)
+
+// runPush collects every target of the config file each scrape interval and pushes its metrics
+// to the Pushgateway, grouped by target, until the context is canceled
+func (c *SherlockCollector) runPush(ctx context.Context, url string) {
+	ticker := time.NewTicker(c.config.ScrapeInterval)
+	defer ticker.Stop()
+
+	for {
+		c.pushTargets(ctx, url)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pushTargets collects all targets concurrently and pushes their metrics, followed by the
+// exporter metrics updated by the collections
+func (c *SherlockCollector) pushTargets(ctx context.Context, url string) {
+	// Finish before the next round starts
+	pushCtx, cancel := context.WithTimeout(ctx, c.config.ScrapeInterval)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, target := range c.config.Targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+
+			if err := c.pushTarget(pushCtx, url, target); err != nil {
+				c.logger.Warn("failed to push metrics", "target", target, "error", err)
+			}
+		}(target.Host)
+	}
+	wg.Wait()
+
+	if err := pushExporterMetrics(pushCtx, url); err != nil {
+		c.logger.Warn("failed to push exporter metrics", "error", err)
+	}
+}
+
+// pushTarget collects a target and pushes its metrics, grouped by target. Only the per-target
+// registry is pushed, since the Pushgateway client rejects metrics carrying the grouping label.
+func (c *SherlockCollector) pushTarget(ctx context.Context, url, target string) error {
+	done := scrapes.start(target)
+	defer done()
+
+	return push.New(url, pushJob).
+		Gatherer(c.targetRegistry(ctx, target, "")).
+		Grouping("target", target).
+		PushContext(ctx)
+}
+
+// pushExporterMetrics pushes the exporter metrics of all targets under their own job
+func pushExporterMetrics(ctx context.Context, url string) error {
+	return push.New(url, pushExporterJob).
+		Gatherer(exporterRegistry).
+		PushContext(ctx)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePushgateway records the paths metrics are pushed to
+type fakePushgateway struct {
+	mutex sync.Mutex
+	paths []string
+}
+
+func (g *fakePushgateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.paths = append(g.paths, r.Method+" "+r.URL.Path)
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestPushTargetsWithTargetLabeledExporterMetrics(t *testing.T) {
+	// Nothing listens on port 1, so the collection fails and records the target's last error
+	const target = "127.0.0.1:1"
+	c := newTestCollector(t, target)
+	c.config.ScrapeInterval = 5 * time.Second
+	targetLastError.WithLabelValues(target, "unreachable").Set(1)
+	defer targetLastError.Reset()
+
+	gateway := &fakePushgateway{}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	ctx := context.Background()
+	if err := c.pushTarget(ctx, server.URL, target); err != nil {
+		t.Fatalf("pushing the target failed: %v", err)
+	}
+	if err := pushExporterMetrics(ctx, server.URL); err != nil {
+		t.Fatalf("pushing the exporter metrics failed: %v", err)
+	}
+
+	sort.Strings(gateway.paths)
+	want := []string{
+		"PUT /metrics/job/sherlock/target/" + target,
+		"PUT /metrics/job/sherlock_exporter",
+	}
+	if len(gateway.paths) != len(want) {
+		t.Fatalf("got pushes %v, want %v", gateway.paths, want)
+	}
+	for i := range want {
+		if gateway.paths[i] != want[i] {
+			t.Errorf("got push %q, want %q", gateway.paths[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// canaryCheck caches the result of the last connection check of the canary target
+type canaryCheck struct {
+	mutex   sync.Mutex
+	checked time.Time
+	err     error
+}
+
+// checkCanary checks the connection to the canary target, reusing the last result while it is
+// more recent than --ready.canary-interval. Concurrent probes wait for a running check.
+func (c *SherlockCollector) checkCanary(target string) error {
+	c.canary.mutex.Lock()
+	defer c.canary.mutex.Unlock()
+
+	if !c.canary.checked.IsZero() && time.Since(c.canary.checked) < *canaryInterval {
+		return c.canary.err
+	}
+
+	client, err := c.getClient(target)
+	if err == nil {
+		_, err = client.Ping()
+	}
+	if err != nil && c.canary.err == nil {
+		c.logger.Warn("canary target unreachable, reporting not ready", "target", target, "error", err)
+	}
+
+	c.canary.checked = time.Now()
+	c.canary.err = err
+	return err
+}
+
+// readyHandler reports whether the exporter is ready to serve scrapes. Without a canary target
+// it always is; with one, only while the canary's Redfish service can be reached, so that an
+// exporter instance on a misconfigured network isn't sent any traffic.
+func (c *SherlockCollector) readyHandler(w http.ResponseWriter, r *http.Request) {
+	if target := normalizeTarget(*canaryTarget); target != "" {
+		if err := c.checkCanary(target); err != nil {
+			http.Error(w, "Error: canary target is unreachable: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	w.Write([]byte("ready\n"))
+}
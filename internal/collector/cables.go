@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/mllnd/sherlock/internal/redfish"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+// CableCollector collects cable and connector metrics
+type CableCollector struct {
+	BaseCollector
+	state  *prometheus.Desc
+	cables map[string]cableReading
+}
+
+type cableReading struct {
+	state     float64
+	name      string
+	cableType string
+	odataID   string
+}
+
+// NewCableCollector creates a new CableCollector
+func NewCableCollector(opts Options) *CableCollector {
+	return &CableCollector{
+		BaseCollector: NewBaseCollector("ipmi", "cable", opts),
+		state: opts.newDesc(
+			"ipmi_cable_state",
+			"Cable connection state (1 = Normal, 0 = Degraded/Failed/Disabled)",
+			opts.componentLabels("name", "cable_type"),
+		),
+		cables: make(map[string]cableReading),
+	}
+}
+
+// Update fetches new metrics and updates the prometheus metrics
+func (c *CableCollector) Update(client *redfish.Client) error {
+	start := time.Now()
+	defer c.RecordScrapeTime(start)
+
+	// Clear previous readings
+	c.mutex.Lock()
+	c.cables = make(map[string]cableReading)
+	c.mutex.Unlock()
+
+	chassis, err := client.GetMainChassis()
+	if err != nil {
+		c.logger.Debug("failed to get main chassis", "error", err)
+		return nil
+	}
+
+	// No cables are returned when the chassis has no Cables link
+	cables, err := chassis.Cables()
+	if err != nil {
+		c.logger.Debug("failed to get cables", "error", err)
+		return c.unsupported(err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, cable := range cables {
+		name := cable.Name
+		if name == "" {
+			name = cable.ID
+		}
+
+		// Cables without any status can't be told to be failed
+		state, ok := cableState(cable)
+		if !ok {
+			continue
+		}
+
+		c.cables[cable.ODataID] = cableReading{
+			state:     state,
+			name:      name,
+			cableType: cable.CableType,
+			odataID:   cable.ODataID,
+		}
+	}
+
+	return nil
+}
+
+// cableState converts the status of a cable to a metric value, using its health when the BMC
+// doesn't report a cable status. It reports false when the BMC reports neither.
+func cableState(cable *gofishredfish.Cable) (float64, bool) {
+	switch cable.CableStatus {
+	case gofishredfish.NormalCableStatus:
+		return 1.0, true
+	case "":
+		switch cable.Status.Health {
+		case "":
+			return 0, false
+		case common.OKHealth:
+			return 1.0, true
+		default:
+			return 0.0, true
+		}
+	default:
+		return 0.0, true
+	}
+}
+
+// Describe describes all metrics this collector exposes
+func (c *CableCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.state
+	c.DescribeScrapeTime(ch)
+}
+
+// Collect collects all metrics
+func (c *CableCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, cable := range c.cables {
+		c.Emit(
+			ch,
+			c.state,
+			prometheus.GaugeValue,
+			cable.state,
+			c.componentValues(cable.odataID, cable.name, cable.cableType)...,
+		)
+	}
+
+	c.CollectScrapeTime(ch)
+}
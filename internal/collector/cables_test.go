@@ -0,0 +1,28 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+func TestCableState(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		cable   gofishredfish.Cable
+		want    float64
+		present bool
+	}{
+		{"normal", gofishredfish.Cable{CableStatus: gofishredfish.NormalCableStatus}, 1, true},
+		{"failed", gofishredfish.Cable{CableStatus: gofishredfish.FailedCableStatus}, 0, true},
+		{"healthy", gofishredfish.Cable{Status: common.Status{Health: common.OKHealth}}, 1, true},
+		{"critical", gofishredfish.Cable{Status: common.Status{Health: common.CriticalHealth}}, 0, true},
+		{"without status", gofishredfish.Cable{}, 0, false},
+	} {
+		state, present := cableState(&tt.cable)
+		if state != tt.want || present != tt.present {
+			t.Errorf("%s: got state %v (present %v), want %v (present %v)", tt.name, state, present, tt.want, tt.present)
+		}
+	}
+}
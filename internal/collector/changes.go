@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// changeTracker remembers the last value exposed for each series and when it was exposed, so
+// that unchanged readings can be repeated with their original timestamp. Prometheus discards a
+// sample it already has, so such readings aren't written again. Collectors are created for every
+// scrape, so the state is shared by all of them, keyed by target, chassis scope and series.
+type changeTracker struct {
+	mutex  sync.Mutex
+	series map[seriesID]exposedValue
+	pruned time.Time
+}
+
+// exposedValue is a value as it was last exposed
+type exposedValue struct {
+	value     float64
+	timestamp time.Time
+}
+
+// changes tracks the exposed values of every target
+var changes = &changeTracker{series: make(map[seriesID]exposedValue)}
+
+// expose returns the value and timestamp to expose a reading with: the previous ones if the
+// reading is within epsilon of the last exposed value and that is younger than maxAge, otherwise
+// the reading itself with the current time
+func (t *changeTracker) expose(key seriesID, value, epsilon float64, maxAge time.Duration) (float64, time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	if last, ok := t.series[key]; ok && math.Abs(value-last.value) <= epsilon && now.Sub(last.timestamp) < maxAge {
+		return last.value, last.timestamp
+	}
+
+	// Forget series that are no longer exposed, every live one is refreshed at least every maxAge
+	if now.Sub(t.pruned) >= maxAge {
+		for k, last := range t.series {
+			if now.Sub(last.timestamp) >= 2*maxAge {
+				delete(t.series, k)
+			}
+		}
+		t.pruned = now
+	}
+
+	t.series[key] = exposedValue{value: value, timestamp: now}
+	return value, now
+}
+
+// seriesID identifies a series by its descriptor, which is cached and shared by every collector,
+// and by its target, chassis scope and label values
+type seriesID struct {
+	desc   *prometheus.Desc
+	series string
+}
+
+// seriesKey identifies a series of a target and chassis scope by its descriptor and label values
+func seriesKey(target, chassisID string, desc *prometheus.Desc, labelValues []string) seriesID {
+	return seriesID{desc: desc, series: target + "\xff" + chassisID + "\xff" + strings.Join(labelValues, "\xff")}
+}
@@ -0,0 +1,270 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mllnd/sherlock/internal/redfish"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+// ChassisCollector collects chassis-level metrics
+type ChassisCollector struct {
+	BaseCollector
+	locationInfo *prometheus.Desc
+	location     *chassisLocation
+
+	// Power state of the chassis, which can disagree with the system's during transitions
+	powerStateDesc *prometheus.Desc
+	powerState     float64
+	powerPresent   bool
+
+	// Every chassis reported by the BMC, for verifying that enclosures are fully enumerated
+	countDesc *prometheus.Desc
+	infoDesc  *prometheus.Desc
+	chassis   []chassisInfo
+	listed    bool
+
+	// Modules (line cards, sleds, blades) contained in the main chassis of modular enclosures
+	moduleHealth healthMetric
+	modules      []moduleReading
+}
+
+type moduleReading struct {
+	id          string
+	chassisType string
+	status      common.Status
+	odataID     string
+}
+
+type chassisInfo struct {
+	id          string
+	chassisType string
+	model       string
+}
+
+type chassisLocation struct {
+	assetTag string
+	location string
+	rack     string
+	rackUnit string
+}
+
+// NewChassisCollector creates a new ChassisCollector
+func NewChassisCollector(opts Options) *ChassisCollector {
+	return &ChassisCollector{
+		BaseCollector: NewBaseCollector("ipmi", "chassis", opts),
+		locationInfo: opts.newDesc(
+			"ipmi_chassis_location_info",
+			"Chassis asset tag and physical location, always 1",
+			[]string{"asset_tag", "location", "rack", "rack_unit"},
+		),
+		powerStateDesc: opts.newDesc(
+			"ipmi_chassis_power_state",
+			"Chassis power state (1 = On, 0 = Off or transitioning)",
+			nil,
+		),
+		countDesc: opts.newDesc(
+			"ipmi_chassis_count",
+			"Number of chassis reported by the BMC",
+			nil,
+		),
+		infoDesc: opts.newDesc(
+			"ipmi_chassis_info",
+			"Chassis reported by the BMC, always 1",
+			[]string{"id", "type", "model"},
+		),
+		moduleHealth: opts.newHealthMetric(
+			"ipmi_module_health",
+			"Health status of a module contained in the main chassis",
+			opts.componentLabels("module_id", "type"),
+		),
+	}
+}
+
+// Update fetches new metrics and updates the prometheus metrics
+func (c *ChassisCollector) Update(client *redfish.Client) error {
+	start := time.Now()
+	defer c.RecordScrapeTime(start)
+
+	// Clear previous readings
+	c.mutex.Lock()
+	c.location = nil
+	c.powerPresent = false
+	c.chassis = nil
+	c.listed = false
+	c.modules = nil
+	c.mutex.Unlock()
+
+	c.listChassis(client)
+
+	chassis, err := client.GetMainChassis()
+	if err != nil {
+		c.logger.Debug("failed to get main chassis", "error", err)
+		return nil
+	}
+
+	c.processModules(chassis)
+
+	rackUnit := ""
+	if chassis.Location.Placement.RackOffset > 0 {
+		rackUnit = strconv.Itoa(chassis.Location.Placement.RackOffset)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.location = &chassisLocation{
+		assetTag: strings.TrimSpace(chassis.AssetTag),
+		location: formatLocation(chassis.Location),
+		rack:     strings.TrimSpace(chassis.Location.Placement.Rack),
+		rackUnit: rackUnit,
+	}
+
+	if chassis.PowerState != "" {
+		c.powerState = 0.0
+		if chassis.PowerState == "On" {
+			c.powerState = 1.0
+		}
+		c.powerPresent = true
+	}
+
+	return nil
+}
+
+// listChassis stores the ID, type and model of every chassis
+func (c *ChassisCollector) listChassis(client *redfish.Client) {
+	all, err := client.GetChassis()
+	if err != nil && len(all) == 0 {
+		c.logger.Debug("failed to get chassis", "error", err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, chassis := range all {
+		if chassis == nil {
+			continue
+		}
+		c.chassis = append(c.chassis, chassisInfo{
+			id:          chassis.ID,
+			chassisType: string(chassis.ChassisType),
+			model:       strings.TrimSpace(chassis.Model),
+		})
+	}
+	c.listed = true
+}
+
+// processModules stores the health of the chassis contained in a chassis, which are the modules
+// of a modular enclosure. Monolithic chassis contain none.
+func (c *ChassisCollector) processModules(chassis *gofishredfish.Chassis) {
+	modules, err := chassis.Contains()
+	if err != nil {
+		c.logger.Debug("failed to get contained chassis", "chassis", chassis.ID, "error", err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, module := range modules {
+		c.modules = append(c.modules, moduleReading{
+			id:          module.ID,
+			chassisType: string(module.ChassisType),
+			status:      module.Status,
+			odataID:     module.ODataID,
+		})
+	}
+}
+
+// formatLocation joins the populated parts of a location from broadest to most specific
+func formatLocation(location common.Location) string {
+	address := location.PostalAddress
+	parts := []string{
+		address.Country,
+		address.City,
+		address.Building,
+		address.Floor,
+		address.Room,
+	}
+	if location.Placement.Row != "" {
+		parts = append(parts, "Row "+location.Placement.Row)
+	}
+
+	var populated []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			populated = append(populated, part)
+		}
+	}
+	return strings.Join(populated, ", ")
+}
+
+// Describe describes all metrics this collector exposes
+func (c *ChassisCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.locationInfo
+	ch <- c.powerStateDesc
+	ch <- c.countDesc
+	ch <- c.infoDesc
+	c.DescribeHealth(ch, c.moduleHealth)
+	c.DescribeScrapeTime(ch)
+}
+
+// Collect collects all metrics
+func (c *ChassisCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.location != nil {
+		c.Emit(
+			ch,
+			c.locationInfo,
+			prometheus.GaugeValue,
+			1,
+			c.location.assetTag,
+			c.location.location,
+			c.location.rack,
+			c.location.rackUnit,
+		)
+	}
+
+	if c.powerPresent {
+		c.Emit(
+			ch,
+			c.powerStateDesc,
+			prometheus.GaugeValue,
+			c.powerState,
+		)
+	}
+
+	if c.listed {
+		c.Emit(
+			ch,
+			c.countDesc,
+			prometheus.GaugeValue,
+			float64(len(c.chassis)),
+		)
+	}
+
+	for _, info := range c.chassis {
+		c.Emit(
+			ch,
+			c.infoDesc,
+			prometheus.GaugeValue,
+			1,
+			info.id,
+			info.chassisType,
+			info.model,
+		)
+	}
+
+	for _, module := range c.modules {
+		c.CollectStatus(ch, c.moduleHealth, module.status, c.componentValues(module.odataID, module.id, module.chassisType)...)
+	}
+
+	c.CollectScrapeTime(ch)
+}
@@ -1,15 +1,37 @@
 package collector
 
 import (
-	"strings"
+	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/mllnd/sherlock/internal/logging"
 	"github.com/mllnd/sherlock/internal/redfish"
+	"github.com/mllnd/sherlock/pkg/utils"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
 )
 
+// SeriesDroppedTotal counts the series dropped because a collector exceeded the series limit
+var SeriesDroppedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "sherlock_series_dropped_total",
+		Help: "Total number of series dropped because a collector exceeded the maximum number of series per scrape",
+	},
+	[]string{"target", "collector"},
+)
+
+// ErrUnsupported is returned by collectors when the BMC doesn't offer the resources they read
+var ErrUnsupported = errors.New("not supported by the BMC")
+
+// IsUnsupported reports whether a collector error means the BMC doesn't support the collector
+func IsUnsupported(err error) bool {
+	return errors.Is(err, ErrUnsupported) || redfish.IsNotFound(err)
+}
+
 // Collector is the interface that all collectors must implement
 type Collector interface {
 	// Update fetches new metrics and updates the prometheus metrics
@@ -23,30 +45,58 @@ type Collector interface {
 
 	// SetTarget sets the target being scraped
 	SetTarget(target string)
+
+	// SetChassis scopes the collector to a specific chassis ID
+	SetChassis(id string)
+
+	// SetContext sets the context of the scrape, whose cancellation stops waiting for retries
+	SetContext(ctx context.Context)
+
+	// Name returns the name of the collector, as used in the config file
+	Name() string
 }
 
 // BaseCollector provides common functionality for all collectors
 type BaseCollector struct {
-	mutex       sync.Mutex
-	lastCollect time.Time
-	scrapeTime  prometheus.Gauge
-	logger      *logging.Logger
-	target      string
+	mutex          sync.Mutex
+	lastCollect    time.Time
+	scrapeTime     *prometheus.Desc
+	scrapeTimeMs   *prometheus.Desc
+	scrapeDuration float64
+	emitted        int
+	dropped        int
+	logger         *logging.Logger
+	subsystem      string
+	target         string
+	chassisID      string
+	ctx            context.Context
+	opts           Options
 }
 
 // NewBaseCollector creates a new BaseCollector
-func NewBaseCollector(namespace, subsystem string) BaseCollector {
+func NewBaseCollector(namespace, subsystem string, opts Options) BaseCollector {
 	return BaseCollector{
-		scrapeTime: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Subsystem: subsystem,
-			Name:      "scrape_duration_seconds",
-			Help:      "Duration of the last scrape in seconds",
-		}),
-		logger: logging.New(),
+		scrapeTime: opts.newDesc(
+			prometheus.BuildFQName(namespace, subsystem, "scrape_duration_seconds"),
+			"Duration of the last scrape in seconds",
+			nil,
+		),
+		scrapeTimeMs: opts.newDesc(
+			prometheus.BuildFQName(namespace, subsystem, "scrape_duration_milliseconds"),
+			"Duration of the last scrape in milliseconds",
+			nil,
+		),
+		logger:    logging.New(subsystem),
+		subsystem: subsystem,
+		opts:      opts,
 	}
 }
 
+// Name returns the name of the collector, which is its metric subsystem
+func (c *BaseCollector) Name() string {
+	return c.subsystem
+}
+
 // SetTarget sets the target being scraped
 func (c *BaseCollector) SetTarget(target string) {
 	c.mutex.Lock()
@@ -54,37 +104,227 @@ func (c *BaseCollector) SetTarget(target string) {
 	c.target = target
 }
 
+// SetChassis scopes the collector to a specific chassis ID (empty = main chassis)
+func (c *BaseCollector) SetChassis(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.chassisID = id
+}
+
+// SetContext sets the context of the scrape, whose cancellation stops waiting for retries
+func (c *BaseCollector) SetContext(ctx context.Context) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.ctx = ctx
+}
+
+// scrapeContext returns the context of the scrape, or the background context if none was set
+func (c *BaseCollector) scrapeContext() context.Context {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// chassisScope returns the chassis selected for this scrape: the requested chassis ID,
+// otherwise the one configured for this collector. It is empty for the main chassis.
+func (c *BaseCollector) chassisScope() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.chassisID != "" {
+		return c.chassisID
+	}
+	if id := c.opts.Chassis[c.subsystem]; id != "main" {
+		return id
+	}
+	return ""
+}
+
+// getChassis returns the selected chassis, defaulting to the main chassis
+func (c *BaseCollector) getChassis(client *redfish.Client) (*gofishredfish.Chassis, error) {
+	switch id := c.chassisScope(); id {
+	case "":
+		return client.GetMainChassis()
+	case "auto":
+		if chassis, err := client.GetMainChassis(); err == nil {
+			return chassis, nil
+		}
+		all, err := client.GetChassis()
+		if err != nil {
+			return nil, err
+		}
+		if len(all) == 0 {
+			return nil, fmt.Errorf("no chassis found")
+		}
+		return all[0], nil
+	default:
+		return client.GetChassisWithID(id)
+	}
+}
+
+// emitZero reports whether this collector emits explicit zero readings for present components
+func (c *BaseCollector) emitZero() bool {
+	return c.opts.EmitZero[c.subsystem]
+}
+
+// unsupported returns an ErrUnsupported error if err means that the BMC doesn't offer the
+// resources of this collector (404), and nil for any other error, which is only logged
+func (c *BaseCollector) unsupported(err error) error {
+	if !redfish.IsNotFound(err) {
+		return nil
+	}
+	return fmt.Errorf("%s collector %w: %v", c.subsystem, ErrUnsupported, err)
+}
+
+// componentValues returns the label values of a per-component metric, adding the Redfish
+// resource path if the odata_id label is enabled
+func (c *BaseCollector) componentValues(odataID string, values ...string) []string {
+	if c.opts.ODataIDLabel {
+		values = append(values, odataID)
+	}
+	return values
+}
+
+// chassisComponentValues returns the label values of a per-component metric that may be merged
+// from every chassis, adding the chassis ID if AllChassis is set
+func (c *BaseCollector) chassisComponentValues(chassisID, odataID string, values ...string) []string {
+	if c.opts.AllChassis {
+		values = append(values, chassisID)
+	}
+	return c.componentValues(odataID, values...)
+}
+
+// chassisKey identifies a component by name, adding the chassis if AllChassis is set so that
+// the same-named components of different chassis are kept apart like their series
+func (c *BaseCollector) chassisKey(chassisID, name string) string {
+	if c.opts.AllChassis {
+		return chassisID + "/" + name
+	}
+	return name
+}
+
 // RecordScrapeTime records the time taken to scrape metrics
 func (c *BaseCollector) RecordScrapeTime(start time.Time) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	duration := time.Since(start).Seconds()
-	c.lastCollect = time.Now()
-	c.scrapeTime.Set(duration)
-
-	// Extract subsystem name from the metric description
-	desc := c.scrapeTime.Desc().String()
-	subsystem := "unknown"
-	if start := strings.Index(desc, "ipmi_"); start != -1 {
-		if end := strings.Index(desc[start:], "_scrape"); end != -1 {
-			subsystem = desc[start : start+end]
-		}
+	if c.opts.ScrapeDurationDecimals >= 0 {
+		duration = utils.Round(duration, c.opts.ScrapeDurationDecimals)
 	}
+	c.lastCollect = time.Now()
+	c.scrapeDuration = duration
+	c.emitted = 0
+	c.dropped = 0
 
 	c.logger.Debugw("scrape completed",
 		"duration_seconds", duration,
-		"subsystem", subsystem,
+		"subsystem", "ipmi_"+c.subsystem,
 		"target", c.target,
 	)
 }
 
+// Emit sends a metric for the given descriptor, applying the shared label value and series limits
+func (c *BaseCollector) Emit(ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType, value float64, labelValues ...string) {
+	// Guard the monitoring backend against BMCs reporting an absurd number of components
+	if c.opts.MaxSeries > 0 {
+		if c.emitted >= c.opts.MaxSeries {
+			if c.dropped == 0 {
+				c.logger.Warn("series limit exceeded, dropping series",
+					"target", c.target,
+					"collector", c.subsystem,
+					"limit", c.opts.MaxSeries,
+				)
+			}
+			c.dropped++
+			SeriesDroppedTotal.WithLabelValues(c.target, c.subsystem).Inc()
+			return
+		}
+		c.emitted++
+	}
+
+	if c.opts.MaxLabelLength > 0 {
+		truncated := make([]string, len(labelValues))
+		for i, labelValue := range labelValues {
+			truncated[i] = utils.Truncate(labelValue, c.opts.MaxLabelLength)
+		}
+		labelValues = truncated
+	}
+
+	// Repeat readings that haven't changed with the timestamp they were first exposed with
+	if c.opts.ChangesOnly && valueType == prometheus.GaugeValue {
+		key := seriesKey(c.target, c.chassisID, desc, labelValues)
+		exposed, timestamp := changes.expose(key, value, c.opts.ChangeEpsilon, c.opts.ChangeMaxAge)
+		ch <- prometheus.NewMetricWithTimestamp(timestamp, prometheus.MustNewConstMetric(desc, valueType, exposed, labelValues...))
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(desc, valueType, value, labelValues...)
+}
+
 // DescribeScrapeTime describes the scrape time metric
 func (c *BaseCollector) DescribeScrapeTime(ch chan<- *prometheus.Desc) {
-	c.scrapeTime.Describe(ch)
+	ch <- c.scrapeTime
+	if c.opts.ScrapeDurationMilliseconds {
+		ch <- c.scrapeTimeMs
+	}
 }
 
 // CollectScrapeTime collects the scrape time metric
 func (c *BaseCollector) CollectScrapeTime(ch chan<- prometheus.Metric) {
-	c.scrapeTime.Collect(ch)
+	ch <- prometheus.MustNewConstMetric(c.scrapeTime, prometheus.GaugeValue, c.scrapeDuration)
+	if c.opts.ScrapeDurationMilliseconds {
+		// Derived from the rounded seconds so that both variants agree
+		ch <- prometheus.MustNewConstMetric(c.scrapeTimeMs, prometheus.GaugeValue, c.scrapeDuration*1000)
+	}
+}
+
+// healthValue converts a Redfish health status to a metric value in the given scheme. A missing
+// status maps to notAvailable if set.
+func healthValue(health common.Health, scheme string, notAvailable *float64) float64 {
+	if health == "" && notAvailable != nil {
+		return *notAvailable
+	}
+
+	if scheme == HealthSchemeSeverity {
+		switch health {
+		case common.OKHealth:
+			return 0.0
+		case common.WarningHealth:
+			return 1.0
+		case common.CriticalHealth:
+			return 2.0
+		default:
+			return 3.0
+		}
+	}
+
+	// Legacy scheme (1 = OK, 0 = Warning/Critical, 2 = Not Available)
+	switch health {
+	case "":
+		return 2.0
+	case common.OKHealth:
+		return 1.0
+	default:
+		return 0.0
+	}
+}
+
+// worstHealth returns the most severe of the given Redfish health statuses
+func worstHealth(healths ...common.Health) common.Health {
+	worst := common.Health("")
+	for _, health := range healths {
+		switch {
+		case health == common.CriticalHealth:
+			return common.CriticalHealth
+		case health == common.WarningHealth:
+			worst = common.WarningHealth
+		case health == common.OKHealth && worst == "":
+			worst = common.OKHealth
+		}
+	}
+	return worst
 }
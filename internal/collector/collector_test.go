@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stmcginnis/gofish/common"
+)
+
+// fakeClient is a gofish client serving canned resources by path
+type fakeClient struct {
+	common.Client
+	resources map[string]string
+}
+
+func (c fakeClient) Get(url string) (*http.Response, error) {
+	body, ok := c.resources[url]
+	if !ok {
+		return nil, &common.Error{HTTPReturnedStatusCode: http.StatusNotFound}
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+// gather collects the metrics of a collector through a registry, failing the test on invalid or
+// duplicate series, and returns them by metric name
+func gather(t *testing.T, c prometheus.Collector) map[string][]*dto.Metric {
+	t.Helper()
+
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(c); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	metrics := make(map[string][]*dto.Metric, len(families))
+	for _, family := range families {
+		metrics[family.GetName()] = family.GetMetric()
+	}
+	return metrics
+}
+
+// labelValue returns the value of a label of a metric, or "" if it has no such label
+func labelValue(m *dto.Metric, name string) string {
+	for _, label := range m.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,182 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mllnd/sherlock/internal/redfish"
+	"github.com/prometheus/client_golang/prometheus"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+// EventCollector counts the recent critical entries in the event logs of the systems
+type EventCollector struct {
+	BaseCollector
+	critical *prometheus.Desc
+	logs     map[string]eventLogReading
+}
+
+type eventLogReading struct {
+	critical float64
+	systemID string
+	log      string
+}
+
+// logEntries is the part of a page of a log entry collection needed to count recent critical
+// entries. Most BMCs return the entries inline; entries that are only linked carry no timestamp.
+type logEntries struct {
+	Members []struct {
+		Created  *string
+		Severity gofishredfish.EventSeverity
+	}
+	Count    int    `json:"Members@odata.count"`
+	NextLink string `json:"Members@odata.nextLink"`
+}
+
+// maxLogPages bounds the pages of a log entry collection read per scrape
+var maxLogPages = 100
+
+// errNoTimestamps means that the entries of a log are only linked, without their creation time
+var errNoTimestamps = errors.New("log entries have no creation time")
+
+// NewEventCollector creates a new EventCollector
+func NewEventCollector(opts Options) *EventCollector {
+	return &EventCollector{
+		BaseCollector: NewBaseCollector("ipmi", "event", opts),
+		critical: opts.newDesc(
+			"ipmi_recent_critical_events",
+			"Number of critical log entries created within CRITICAL_EVENTS_WINDOW",
+			[]string{"system_id", "log"},
+		),
+		logs: make(map[string]eventLogReading),
+	}
+}
+
+// Update fetches new metrics and updates the prometheus metrics
+func (c *EventCollector) Update(client *redfish.Client) error {
+	if c.opts.CriticalEventsWindow <= 0 {
+		return nil
+	}
+
+	start := time.Now()
+	defer c.RecordScrapeTime(start)
+
+	// Clear previous readings
+	c.mutex.Lock()
+	c.logs = make(map[string]eventLogReading)
+	c.mutex.Unlock()
+
+	systems, err := client.GetSystems()
+	if err != nil {
+		c.logger.Debug("failed to get systems", "error", err)
+		return nil
+	}
+
+	since := time.Now().Add(-c.opts.CriticalEventsWindow)
+	for _, system := range systems {
+		services, err := system.LogServices()
+		if err != nil {
+			c.logger.Debug("failed to get log services", "system", system.ID, "error", err)
+			continue
+		}
+
+		for _, service := range services {
+			critical, complete, err := countCriticalEntries(client.GetRaw, service.ODataID+"/Entries", since, client.SupportsTopSkip())
+			if err != nil {
+				c.logger.Debug("failed to get log entries", "system", system.ID, "log", service.ID, "error", err)
+				continue
+			}
+			if !complete {
+				c.logger.Debug("log has more entries than are read per scrape, critical events may be undercounted",
+					"system", system.ID, "log", service.ID, "pages", maxLogPages)
+			}
+
+			c.mutex.Lock()
+			c.logs[service.ODataID] = eventLogReading{
+				critical: float64(critical),
+				systemID: system.ID,
+				log:      service.ID,
+			}
+			c.mutex.Unlock()
+		}
+	}
+
+	return nil
+}
+
+// countCriticalEntries counts the critical entries of a log entry collection created since the
+// given time. The entries are read page by page with get instead of one request per entry, up to
+// maxLogPages pages. Logs usually list the oldest entries first, so when a log has more pages and
+// the service supports $skip, the pages after the first one are read from the newest end. It
+// reports whether every entry was read, and returns errNoTimestamps if the entries lack their
+// creation time, since they can't be counted.
+func countCriticalEntries(get func(path string) ([]byte, error), link string, since time.Time, topSkip bool) (int, bool, error) {
+	collection := link
+	critical, skipped := 0, false
+	for page := 0; link != "" && page < maxLogPages; page++ {
+		body, err := get(link)
+		if err != nil {
+			return 0, false, err
+		}
+
+		var entries logEntries
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return 0, false, err
+		}
+
+		for _, entry := range entries.Members {
+			if entry.Created == nil {
+				return 0, false, errNoTimestamps
+			}
+			if entry.Severity != gofishredfish.CriticalEventSeverity {
+				continue
+			}
+			if created, err := time.Parse(time.RFC3339, *entry.Created); err == nil && created.After(since) {
+				critical++
+			}
+		}
+		link = entries.NextLink
+
+		// Skip to the pages holding the newest entries if the remaining ones don't fit
+		size := len(entries.Members)
+		if page == 0 && link != "" && topSkip && size > 0 && entries.Count > size*maxLogPages {
+			link = fmt.Sprintf("%s?$skip=%d", collection, entries.Count-size*(maxLogPages-1))
+			skipped = true
+		}
+	}
+	return critical, link == "" && !skipped, nil
+}
+
+// Describe describes all metrics this collector exposes
+func (c *EventCollector) Describe(ch chan<- *prometheus.Desc) {
+	if c.opts.CriticalEventsWindow <= 0 {
+		return
+	}
+	ch <- c.critical
+	c.DescribeScrapeTime(ch)
+}
+
+// Collect collects all metrics
+func (c *EventCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.opts.CriticalEventsWindow <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, reading := range c.logs {
+		c.Emit(
+			ch,
+			c.critical,
+			prometheus.GaugeValue,
+			reading.critical,
+			reading.systemID,
+			reading.log,
+		)
+	}
+
+	c.CollectScrapeTime(ch)
+}
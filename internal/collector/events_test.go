@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// getPages returns a function reading the given pages by path
+func getPages(pages map[string]string) func(path string) ([]byte, error) {
+	return func(path string) ([]byte, error) {
+		page, ok := pages[path]
+		if !ok {
+			return nil, fmt.Errorf("%s not found", path)
+		}
+		return []byte(page), nil
+	}
+}
+
+func TestCountCriticalEntriesFollowsPages(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-time.Minute).Format(time.RFC3339)
+	old := now.Add(-time.Hour).Format(time.RFC3339)
+
+	const entries = "/redfish/v1/Systems/1/LogServices/SEL/Entries"
+	get := getPages(map[string]string{
+		entries: fmt.Sprintf(`{
+			"Members": [
+				{"Created": %q, "Severity": "Critical"},
+				{"Created": %q, "Severity": "OK"}
+			],
+			"Members@odata.nextLink": "%s?$skip=2"
+		}`, recent, recent, entries),
+		entries + "?$skip=2": fmt.Sprintf(`{
+			"Members": [
+				{"Created": %q, "Severity": "Critical"},
+				{"Created": %q, "Severity": "Critical"}
+			]
+		}`, recent, old),
+	})
+
+	critical, complete, err := countCriticalEntries(get, entries, now.Add(-15*time.Minute), false)
+	if err != nil {
+		t.Fatalf("failed to count entries: %v", err)
+	}
+	if critical != 2 || !complete {
+		t.Errorf("got %d recent critical entries (complete %v), want 2 from all entries", critical, complete)
+	}
+}
+
+func TestCountCriticalEntriesWithoutTimestamps(t *testing.T) {
+	const entries = "/redfish/v1/Systems/1/LogServices/SEL/Entries"
+	get := getPages(map[string]string{
+		entries: `{"Members": [{"@odata.id": "/redfish/v1/Systems/1/LogServices/SEL/Entries/1"}]}`,
+	})
+
+	if _, _, err := countCriticalEntries(get, entries, time.Now(), false); !errors.Is(err, errNoTimestamps) {
+		t.Errorf("got error %v for linked entries, want errNoTimestamps", err)
+	}
+}
+
+func TestCountCriticalEntriesReadsTheNewestPages(t *testing.T) {
+	original := maxLogPages
+	defer func() { maxLogPages = original }()
+	maxLogPages = 2
+
+	now := time.Now()
+	recent := now.Add(-time.Minute).Format(time.RFC3339)
+	old := now.Add(-time.Hour).Format(time.RFC3339)
+
+	// Four pages of two entries, oldest first, with the recent critical entries on the last one
+	const entries = "/redfish/v1/Systems/1/LogServices/SEL/Entries"
+	page := func(created, next string) string {
+		return fmt.Sprintf(`{
+			"Members": [{"Created": %q, "Severity": "Critical"}, {"Created": %q, "Severity": "OK"}],
+			"Members@odata.count": 8,
+			"Members@odata.nextLink": %q
+		}`, created, created, next)
+	}
+	pages := map[string]string{
+		entries:              page(old, entries+"?$skip=2"),
+		entries + "?$skip=2": page(old, entries+"?$skip=4"),
+		entries + "?$skip=4": page(old, entries+"?$skip=6"),
+		entries + "?$skip=6": page(recent, ""),
+	}
+
+	critical, complete, err := countCriticalEntries(getPages(pages), entries, now.Add(-15*time.Minute), false)
+	if err != nil {
+		t.Fatalf("failed to count entries: %v", err)
+	}
+	if critical != 0 || complete {
+		t.Errorf("got %d recent critical entries (complete %v) without $skip, want 0 from an incomplete read", critical, complete)
+	}
+
+	critical, complete, err = countCriticalEntries(getPages(pages), entries, now.Add(-15*time.Minute), true)
+	if err != nil {
+		t.Fatalf("failed to count entries: %v", err)
+	}
+	if critical != 1 || complete {
+		t.Errorf("got %d recent critical entries (complete %v) with $skip, want 1 from the newest page", critical, complete)
+	}
+}
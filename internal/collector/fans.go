@@ -1,51 +1,133 @@
 package collector
 
 import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/mllnd/sherlock/internal/redfish"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
 )
 
 // FansCollector collects fan metrics
 type FansCollector struct {
 	BaseCollector
-	health *prometheus.Desc
-	state  *prometheus.Desc
-	speed  *prometheus.Desc
-	fans   map[string]fanMetric
+	health  healthMetric
+	state   *prometheus.Desc
+	speed   *prometheus.Desc
+	percent *prometheus.Desc
+	stopped *prometheus.Desc
+	count   *prometheus.Desc
+	fans    map[string]fanMetric
+
+	// Reading range of the fans, for scaling speeds
+	speedMin *prometheus.Desc
+	speedMax *prometheus.Desc
+
+	// Rolled-up health of the thermal subsystem
+	thermalHealth    healthMetric
+	subsystemHealth  common.Health
+	subsystemPresent bool
+
+	// Single thermal verdict of the chassis, from the rolled-up health of the thermal subsystem
+	thermalState *prometheus.Desc
+
+	// Summarized temperatures of the thermal metrics by location, only read from BMCs
+	// implementing the ThermalSubsystem schema
+	temperatureSummary *prometheus.Desc
+	temperatures       map[string]float64
+
+	// Chassis airflow, reported by some BMCs in the thermal OEM section
+	airflowDesc    *prometheus.Desc
+	airflow        float64
+	airflowPresent bool
 }
 
 type fanMetric struct {
-	health float64
-	state  float64
-	speed  float64
-	name   string
+	status         common.Status
+	state          float64
+	speed          float64
+	speedPresent   bool
+	percent        float64
+	percentPresent bool
+	minSpeed       float64
+	maxSpeed       float64
+	rangePresent   bool
+	name           string
+	chassis        string
+	odataID        string
 }
 
 // NewFansCollector creates a new FansCollector
-func NewFansCollector() *FansCollector {
+func NewFansCollector(opts Options) *FansCollector {
 	return &FansCollector{
-		BaseCollector: NewBaseCollector("ipmi", "fan"),
-		health: prometheus.NewDesc(
+		BaseCollector: NewBaseCollector("ipmi", "fan", opts),
+		health: opts.newHealthMetric(
 			"ipmi_fan_health",
-			"Fan health status (1 = OK, 0 = Warning/Critical, 2 = Not Available)",
-			[]string{"name"},
-			nil,
+			"Fan health status",
+			opts.chassisComponentLabels("name"),
 		),
-		state: prometheus.NewDesc(
+		state: opts.newDesc(
 			"ipmi_fan_state",
 			"Fan operating state (1 = Enabled, 0 = Disabled)",
-			[]string{"name"},
-			nil,
+			opts.chassisComponentLabels("name"),
 		),
-		speed: prometheus.NewDesc(
+		speed: opts.newDesc(
 			"ipmi_fan_speed_rpm",
 			"Fan speed in RPM",
-			[]string{"name"},
+			opts.chassisComponentLabels("name"),
+		),
+		percent: opts.newDesc(
+			"ipmi_fan_speed_percent",
+			"Fan speed in percent of its maximum speed",
+			opts.chassisComponentLabels("name"),
+		),
+		stopped: opts.newDesc(
+			"ipmi_fan_stopped",
+			"Whether an enabled fan reports 0 RPM with degraded health (1 = stopped, 0 = spinning, disabled or healthy)",
+			opts.chassisComponentLabels("name"),
+		),
+		speedMin: opts.newDesc(
+			"ipmi_fan_speed_min_rpm",
+			"Lowest possible fan speed reading in RPM",
+			opts.chassisComponentLabels("name"),
+		),
+		speedMax: opts.newDesc(
+			"ipmi_fan_speed_max_rpm",
+			"Highest possible fan speed reading in RPM",
+			opts.chassisComponentLabels("name"),
+		),
+		count: opts.newDesc(
+			"ipmi_fan_count",
+			"Number of fans reported by the BMC",
+			nil,
+		),
+		thermalHealth: opts.newHealthMetric(
+			"ipmi_thermal_subsystem_health",
+			"Thermal subsystem health status",
+			nil,
+		),
+		thermalState: opts.newDesc(
+			"ipmi_chassis_thermal_state",
+			"Rolled-up thermal state of the chassis (0 = Normal, 1 = Warning, 2 = Critical)",
 			nil,
 		),
-		fans: make(map[string]fanMetric),
+		temperatureSummary: opts.newDesc(
+			"ipmi_chassis_temperature_summary_celsius",
+			"Summarized chassis temperature in degree Celsius by location (ambient, exhaust, intake, internal)",
+			[]string{"location"},
+		),
+		airflowDesc: opts.newDesc(
+			"ipmi_chassis_airflow_cfm",
+			"Chassis airflow in cubic feet per minute",
+			nil,
+		),
+		fans:         make(map[string]fanMetric),
+		temperatures: make(map[string]float64),
 	}
 }
 
@@ -57,64 +139,266 @@ func (c *FansCollector) Update(client *redfish.Client) error {
 	// Clear previous readings
 	c.mutex.Lock()
 	c.fans = make(map[string]fanMetric)
+	c.subsystemHealth = ""
+	c.subsystemPresent = false
+	c.temperatures = make(map[string]float64)
+	c.airflowPresent = false
 	c.mutex.Unlock()
 
-	// Get main chassis (ID 1)
-	chassis, err := client.GetMainChassis()
+	// Merge the fans of every chassis if requested and no chassis was specified
+	if c.opts.AllChassis && c.chassisScope() == "" {
+		return client.ForEachChassis(c.opts.ChassisWorkers, func(chassis *gofishredfish.Chassis) {
+			if err := c.collectChassis(chassis); err != nil {
+				c.logger.Debug("failed to get thermal information", "chassis", chassis.ID, "error", err)
+			}
+		})
+	}
+
+	// Get the requested chassis (ID 1 by default)
+	chassis, err := c.getChassis(client)
 	if err != nil {
-		c.logger.Debug("failed to get main chassis", "error", err)
+		c.logger.Debug("failed to get chassis", "error", err)
 		return nil
 	}
 
 	// Get thermal information
-	thermal, err := chassis.Thermal()
-	if err != nil {
+	if err := c.collectChassis(chassis); err != nil {
 		c.logger.Debug("failed to get thermal information", "error", err)
-		return nil
+		return c.unsupported(err)
+	}
+
+	return nil
+}
+
+// collectChassis stores the fan readings of a chassis. BMCs implementing the newer schema expose
+// fans as individual resources of the ThermalSubsystem instead of in the legacy Thermal resource,
+// so those are read when the Thermal resource has no fans.
+func (c *FansCollector) collectChassis(chassis *gofishredfish.Chassis) error {
+	thermal, err := c.fetchThermal(chassis, "Fans")
+	if err == nil {
+		c.processThermal(chassis.ID, thermal)
+		if len(thermal.Fans) > 0 {
+			return nil
+		}
+	}
+
+	subsystem, subsystemErr := chassis.ThermalSubsystem()
+	if subsystemErr != nil {
+		return fmt.Errorf("failed to get thermal subsystem: %w", subsystemErr)
+	}
+	if subsystem == nil {
+		return err
+	}
+
+	// The gofish fans decode absent speed readings as 0, so list the collection with the
+	// presence of each reading
+	fans, subsystemErr := common.GetCollectionObjects[subsystemFan](subsystem.GetClient(), subsystem.ODataID+"/Fans")
+	if subsystemErr != nil {
+		return fmt.Errorf("failed to get thermal subsystem fans: %w", subsystemErr)
 	}
 
+	c.processThermalSubsystem(chassis.ID, subsystem, fans)
+
+	// The thermal metrics are optional, a failure to read them leaves the fans collected
+	raw := newRawClient(subsystem.GetClient())
+	subsystem.SetClient(raw)
+	if metrics, err := subsystem.ThermalMetrics(); err != nil {
+		c.logger.Debug("failed to get thermal metrics", "chassis", chassis.ID, "error", err)
+	} else if metrics != nil {
+		c.processTemperatureSummary(metrics.TemperatureSummaryCelsius, func(location string) bool {
+			return raw.has(metrics.ODataID, "TemperatureSummaryCelsius", location, "Reading")
+		})
+	}
+
+	return nil
+}
+
+// processThermal stores the fan readings of the thermal resource of a chassis
+func (c *FansCollector) processThermal(chassisID string, thermal *gofishredfish.Thermal) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	c.subsystemHealth = worstHealth(c.subsystemHealth, thermal.Status.Health)
+	c.subsystemPresent = true
+
+	// Keep the first airflow reading found
+	if airflow, ok := oemAirflowCFM(thermal.Oem); ok && !c.airflowPresent {
+		c.airflow = airflow
+		c.airflowPresent = true
+	}
+
 	// Process all fans
 	for _, fan := range thermal.Fans {
-		// Skip if no readings available
-		if fan.Name == "" {
+		// Skip if no readings available or filtered out
+		if fan.Name == "" || !c.opts.keepSensor(fan.Name) {
 			continue
 		}
 
-		// Convert health status to float64
-		health := 2.0 // Default to Not Available
-		if fan.Status.Health != "" {
-			if fan.Status.Health == "OK" {
-				health = 1.0
-			} else {
-				health = 0.0
-			}
+		reading := fanMetric{
+			status:  fan.Status,
+			state:   fanState(fan.Status),
+			name:    fan.Name,
+			chassis: chassisID,
+			odataID: fan.ODataID,
 		}
 
-		// Convert operating state to float64
-		state := 0.0
-		if fan.Status.State == "Enabled" {
-			state = 1.0
+		// Readings without units are in RPM. The reading range is only meaningful when the BMC
+		// reports its upper bound.
+		if fan.ReadingUnits == gofishredfish.PercentReadingUnits {
+			reading.percent = float64(fan.Reading)
+			reading.percentPresent = true
+		} else {
+			reading.speed = float64(fan.Reading)
+			reading.speedPresent = true
+			reading.minSpeed = float64(fan.MinReadingRange)
+			reading.maxSpeed = float64(fan.MaxReadingRange)
+			reading.rangePresent = fan.MaxReadingRange > 0
 		}
+		c.fans[c.chassisKey(chassisID, fan.Name)] = reading
+	}
+}
 
-		c.fans[fan.Name] = fanMetric{
-			health: health,
-			state:  state,
-			speed:  float64(fan.Reading),
-			name:   fan.Name,
+// processThermalSubsystem stores the readings of the fans of the thermal subsystem of a chassis
+func (c *FansCollector) processThermalSubsystem(chassisID string, subsystem *gofishredfish.ThermalSubsystem, fans []*subsystemFan) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.subsystemHealth = worstHealth(c.subsystemHealth, subsystem.Status.Health)
+	c.subsystemPresent = true
+
+	for _, fan := range fans {
+		// Skip if no readings available or filtered out
+		if fan.Name == "" || !c.opts.keepSensor(fan.Name) {
+			continue
+		}
+
+		c.fans[c.chassisKey(chassisID, fan.Name)] = fanMetric{
+			status:         fan.Status,
+			state:          fanState(fan.Status),
+			speed:          fan.SpeedPercent.SpeedRPM,
+			speedPresent:   fan.rpmPresent,
+			percent:        fan.SpeedPercent.Reading,
+			percentPresent: fan.percentPresent,
+			name:           fan.Name,
+			chassis:        chassisID,
+			odataID:        fan.ODataID,
 		}
 	}
+}
 
-	return nil
+// processTemperatureSummary stores the summarized temperatures the BMC reports, as told by present
+// for each location of the JSON (Ambient, Exhaust, Intake or Internal)
+func (c *FansCollector) processTemperatureSummary(summary gofishredfish.TemperatureSummary, present func(location string) bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	locations := map[string]gofishredfish.SensorExcerpt{
+		"Ambient":  summary.Ambient,
+		"Exhaust":  summary.Exhaust,
+		"Intake":   summary.Intake,
+		"Internal": summary.Internal,
+	}
+	for property, reading := range locations {
+		// Keep the first reading found when merging several chassis
+		location := strings.ToLower(property)
+		if _, ok := c.temperatures[location]; ok || !present(property) {
+			continue
+		}
+		c.temperatures[location] = float64(reading.Reading)
+	}
+}
+
+// thermalState converts a rolled-up thermal health to a state value, false if it isn't reported
+func thermalState(health common.Health) (float64, bool) {
+	switch health {
+	case common.OKHealth:
+		return 0, true
+	case common.WarningHealth:
+		return 1, true
+	case common.CriticalHealth:
+		return 2, true
+	}
+	return 0, false
+}
+
+// fanState converts the operating state of a fan to a metric value
+func fanState(status common.Status) float64 {
+	if status.State == "Enabled" {
+		return 1.0
+	}
+	return 0.0
+}
+
+// fanStopped reports whether a fan has stopped: it reports 0 RPM while enabled and unhealthy.
+// Disabled fans, fans idling at 0 RPM by design with good health and fans without an RPM
+// reading are not stopped.
+func fanStopped(reading fanMetric) bool {
+	if !reading.speedPresent || reading.speed != 0 || reading.status.State != common.EnabledState {
+		return false
+	}
+	return reading.status.Health == common.WarningHealth || reading.status.Health == common.CriticalHealth
+}
+
+// oemAirflowCFM searches a thermal OEM section for an airflow reading in cubic feet per minute.
+// Readings reported in cubic meters per minute (CMM) are converted.
+func oemAirflowCFM(oem json.RawMessage) (float64, bool) {
+	if len(oem) == 0 {
+		return 0, false
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(oem, &data); err != nil {
+		return 0, false
+	}
+
+	var search func(value interface{}) (float64, bool)
+	search = func(value interface{}) (float64, bool) {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return 0, false
+		}
+
+		// Walk the keys in order so that the same reading wins on every scrape when several
+		// vendor sections carry one
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			field := object[key]
+			lowerKey := strings.ToLower(key)
+			if reading, ok := field.(float64); ok && strings.Contains(lowerKey, "airflow") {
+				if strings.Contains(lowerKey, "cmm") || strings.Contains(lowerKey, "cubicmeters") {
+					return reading * 35.3147, true
+				}
+				return reading, true
+			}
+			if reading, ok := search(field); ok {
+				return reading, true
+			}
+		}
+		return 0, false
+	}
+
+	return search(data)
 }
 
 // Describe describes all metrics this collector exposes
 func (c *FansCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- c.health
+	c.DescribeHealth(ch, c.health)
 	ch <- c.state
 	ch <- c.speed
+	ch <- c.percent
+	ch <- c.stopped
+	ch <- c.speedMin
+	ch <- c.speedMax
+	ch <- c.count
+	c.DescribeHealth(ch, c.thermalHealth)
+	ch <- c.thermalState
+	ch <- c.temperatureSummary
+	ch <- c.airflowDesc
 	c.DescribeScrapeTime(ch)
 }
 
@@ -124,27 +408,134 @@ func (c *FansCollector) Collect(ch chan<- prometheus.Metric) {
 	defer c.mutex.Unlock()
 
 	for _, reading := range c.fans {
-		ch <- prometheus.MustNewConstMetric(
-			c.health,
-			prometheus.GaugeValue,
-			reading.health,
-			reading.name,
-		)
+		c.CollectStatus(ch, c.health, reading.status, c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...)
 
-		ch <- prometheus.MustNewConstMetric(
+		c.Emit(
+			ch,
 			c.state,
 			prometheus.GaugeValue,
 			reading.state,
-			reading.name,
+			c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+		)
+
+		if reading.speedPresent {
+			c.Emit(
+				ch,
+				c.speed,
+				prometheus.GaugeValue,
+				reading.speed,
+				c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+			)
+		}
+
+		if reading.percentPresent {
+			c.Emit(
+				ch,
+				c.percent,
+				prometheus.GaugeValue,
+				reading.percent,
+				c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+			)
+		}
+
+		stopped := 0.0
+		if fanStopped(reading) {
+			stopped = 1.0
+		}
+		c.Emit(
+			ch,
+			c.stopped,
+			prometheus.GaugeValue,
+			stopped,
+			c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+		)
+
+		if reading.rangePresent {
+			c.Emit(
+				ch,
+				c.speedMin,
+				prometheus.GaugeValue,
+				reading.minSpeed,
+				c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+			)
+
+			c.Emit(
+				ch,
+				c.speedMax,
+				prometheus.GaugeValue,
+				reading.maxSpeed,
+				c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+			)
+		}
+	}
+
+	// A failed fetch is no evidence of missing fans
+	if c.subsystemPresent {
+		c.Emit(
+			ch,
+			c.count,
+			prometheus.GaugeValue,
+			float64(len(c.fans)),
 		)
 
-		ch <- prometheus.MustNewConstMetric(
-			c.speed,
+		c.CollectHealth(ch, c.thermalHealth, c.subsystemHealth)
+	}
+
+	if state, ok := thermalState(c.subsystemHealth); ok {
+		c.Emit(
+			ch,
+			c.thermalState,
 			prometheus.GaugeValue,
-			reading.speed,
-			reading.name,
+			state,
+		)
+	}
+
+	for location, temperature := range c.temperatures {
+		c.Emit(
+			ch,
+			c.temperatureSummary,
+			prometheus.GaugeValue,
+			temperature,
+			location,
+		)
+	}
+
+	if c.airflowPresent {
+		c.Emit(
+			ch,
+			c.airflowDesc,
+			prometheus.GaugeValue,
+			c.airflow,
 		)
 	}
 
 	c.CollectScrapeTime(ch)
 }
+
+// subsystemFan is a fan of a thermal subsystem that records which of its speed readings the BMC
+// reports, since gofish decodes absent readings as 0
+type subsystemFan struct {
+	gofishredfish.Fan
+	rpmPresent     bool
+	percentPresent bool
+}
+
+// UnmarshalJSON decodes a fan and the presence of its speed readings
+func (fan *subsystemFan) UnmarshalJSON(b []byte) error {
+	if err := fan.Fan.UnmarshalJSON(b); err != nil {
+		return err
+	}
+
+	var t struct {
+		SpeedPercent struct {
+			Reading  *float64
+			SpeedRPM *float64
+		}
+	}
+	if err := json.Unmarshal(b, &t); err != nil {
+		return err
+	}
+	fan.rpmPresent = t.SpeedPercent.SpeedRPM != nil
+	fan.percentPresent = t.SpeedPercent.Reading != nil
+	return nil
+}
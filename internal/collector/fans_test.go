@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+func TestFansOfAllChassisStayApart(t *testing.T) {
+	c := NewFansCollector(Options{AllChassis: true})
+	for _, chassis := range []string{"Blade1", "Blade2"} {
+		c.processThermal(chassis, &gofishredfish.Thermal{
+			Fans: []gofishredfish.ThermalFan{{
+				Entity: common.Entity{Name: "Fan 1"},
+				Status: common.Status{State: common.EnabledState, Health: common.OKHealth},
+			}},
+		})
+	}
+
+	metrics := gather(t, c)
+	speeds := metrics["ipmi_fan_speed_rpm"]
+	if len(speeds) != 2 {
+		t.Fatalf("got %d fan speeds, want one per chassis", len(speeds))
+	}
+	if labelValue(speeds[0], "chassis") == labelValue(speeds[1], "chassis") {
+		t.Errorf("both fans are labeled with chassis %q", labelValue(speeds[0], "chassis"))
+	}
+	if count := metrics["ipmi_fan_count"][0].GetGauge().GetValue(); count != 2 {
+		t.Errorf("got fan count %v, want 2", count)
+	}
+}
+
+// subsystemFans is a FanCollection of a ThermalSubsystem with one fan reporting its speed in RPM
+// and one only in percent
+const subsystemFans = `[
+	{
+		"@odata.id": "/redfish/v1/Chassis/1/ThermalSubsystem/Fans/Fan1",
+		"Id": "Fan1",
+		"Name": "Fan 1",
+		"SpeedPercent": {"Reading": 0, "SpeedRPM": 0},
+		"Status": {"State": "Enabled", "Health": "Critical"}
+	},
+	{
+		"@odata.id": "/redfish/v1/Chassis/1/ThermalSubsystem/Fans/Fan2",
+		"Id": "Fan2",
+		"Name": "Fan 2",
+		"SpeedPercent": {"Reading": 45},
+		"Status": {"State": "Enabled", "Health": "Warning"}
+	}
+]`
+
+func TestThermalSubsystemFanSpeeds(t *testing.T) {
+	var fans []*subsystemFan
+	if err := json.Unmarshal([]byte(subsystemFans), &fans); err != nil {
+		t.Fatalf("failed to decode fans: %v", err)
+	}
+
+	c := NewFansCollector(Options{})
+	c.processThermalSubsystem("1", &gofishredfish.ThermalSubsystem{}, fans)
+	metrics := gather(t, c)
+
+	speeds := metrics["ipmi_fan_speed_rpm"]
+	if len(speeds) != 1 || labelValue(speeds[0], "name") != "Fan 1" {
+		t.Errorf("got RPM speeds %v, want only Fan 1", speeds)
+	}
+
+	percents := metrics["ipmi_fan_speed_percent"]
+	if len(percents) != 2 {
+		t.Fatalf("got %d percent speeds, want 2", len(percents))
+	}
+	for _, m := range percents {
+		if labelValue(m, "name") == "Fan 2" && m.GetGauge().GetValue() != 45 {
+			t.Errorf("got Fan 2 speed %v%%, want 45%%", m.GetGauge().GetValue())
+		}
+	}
+
+	// A fan without an RPM reading can't tell whether it spins
+	for _, m := range metrics["ipmi_fan_stopped"] {
+		want := map[string]float64{"Fan 1": 1, "Fan 2": 0}[labelValue(m, "name")]
+		if got := m.GetGauge().GetValue(); got != want {
+			t.Errorf("got %s stopped %v, want %v", labelValue(m, "name"), got, want)
+		}
+	}
+}
+
+func TestFanCountOnlyAfterReadingThermal(t *testing.T) {
+	c := NewFansCollector(Options{})
+	if counts := gather(t, c)["ipmi_fan_count"]; len(counts) != 0 {
+		t.Errorf("got fan count %v without reading the thermal information", counts[0].GetGauge().GetValue())
+	}
+
+	c.processThermal("1", &gofishredfish.Thermal{})
+	counts := gather(t, c)["ipmi_fan_count"]
+	if len(counts) != 1 || counts[0].GetGauge().GetValue() != 0 {
+		t.Errorf("got fan counts %v, want 0 for a thermal resource without fans", counts)
+	}
+}
+
+func TestOEMAirflowIsStable(t *testing.T) {
+	oem := json.RawMessage(`{
+		"Vendor2": {"AirflowCMM": 2},
+		"Vendor1": {"Airflow": 40},
+		"Vendor3": {"SystemAirflow": 90}
+	}`)
+
+	for i := 0; i < 20; i++ {
+		if cfm, ok := oemAirflowCFM(oem); !ok || cfm != 40 {
+			t.Fatalf("got airflow %v, %v, want 40 CFM from the first vendor section", cfm, ok)
+		}
+	}
+}
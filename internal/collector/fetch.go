@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+// retryEmpty calls fetch until it returns a non-empty result, retrying up to EmptyRetries
+// times after EmptyRetryDelay. BMCs sometimes transiently return successful but empty resources.
+// Retries stop once the scrape is cancelled, keeping the empty result.
+func (c *BaseCollector) retryEmpty(fetch func() (empty bool, err error)) error {
+	for attempt := 0; ; attempt++ {
+		empty, err := fetch()
+		if err != nil || !empty || attempt >= c.opts.EmptyRetries {
+			return err
+		}
+
+		c.logger.Debug("empty result, retrying", "subsystem", c.subsystem, "attempt", attempt+1)
+		timer := time.NewTimer(c.opts.EmptyRetryDelay)
+		select {
+		case <-timer.C:
+		case <-c.scrapeContext().Done():
+			timer.Stop()
+			return nil
+		}
+	}
+}
+
+// fetchThermal reads the thermal resource of a chassis, retrying while it lists the given
+// collection, e.g. Fans, without any members. A chassis that doesn't list the collection at all
+// has none of its components, so it isn't retried.
+func (c *BaseCollector) fetchThermal(chassis *gofishredfish.Chassis, collection string) (*gofishredfish.Thermal, error) {
+	client := chassis.GetClient()
+	defer chassis.SetClient(client)
+	raw := newRawClient(client)
+	chassis.SetClient(raw)
+
+	var thermal *gofishredfish.Thermal
+	err := c.retryEmpty(func() (bool, error) {
+		var err error
+		if thermal, err = chassis.Thermal(); err != nil {
+			return false, err
+		}
+		if thermal == nil {
+			return false, fmt.Errorf("chassis %s has no thermal resource", chassis.ID)
+		}
+		return raw.emptyCollection(thermal.ODataID, collection), nil
+	})
+	return thermal, err
+}
+
+// fetchPower reads the power resource of a chassis, retrying while it lists the given collection,
+// e.g. PowerSupplies, without any members. A chassis that doesn't list the collection at all has
+// none of its components, so it isn't retried.
+func (c *BaseCollector) fetchPower(chassis *gofishredfish.Chassis, collection string) (*gofishredfish.Power, error) {
+	client := chassis.GetClient()
+	defer chassis.SetClient(client)
+	raw := newRawClient(client)
+	chassis.SetClient(raw)
+
+	var power *gofishredfish.Power
+	err := c.retryEmpty(func() (bool, error) {
+		var err error
+		if power, err = chassis.Power(); err != nil {
+			return false, err
+		}
+		if power == nil {
+			return false, fmt.Errorf("chassis %s has no power resource", chassis.ID)
+		}
+		return raw.emptyCollection(power.ODataID, collection), nil
+	})
+	return power, err
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+// countingClient is a fakeClient counting the reads of each path
+type countingClient struct {
+	fakeClient
+	reads map[string]int
+}
+
+func (c *countingClient) Get(url string) (*http.Response, error) {
+	c.reads[url]++
+	return c.fakeClient.Get(url)
+}
+
+// thermalChassis returns a chassis whose thermal resource is the given JSON
+func thermalChassis(t *testing.T, thermal string) (*gofishredfish.Chassis, *countingClient) {
+	t.Helper()
+
+	client := &countingClient{reads: make(map[string]int), fakeClient: fakeClient{resources: map[string]string{
+		"/redfish/v1/Chassis/1": `{
+			"@odata.id": "/redfish/v1/Chassis/1",
+			"Id": "1",
+			"Thermal": {"@odata.id": "/redfish/v1/Chassis/1/Thermal"}
+		}`,
+		"/redfish/v1/Chassis/1/Thermal": thermal,
+	}}}
+	chassis, err := gofishredfish.GetChassis(client, "/redfish/v1/Chassis/1")
+	if err != nil {
+		t.Fatalf("failed to read the chassis: %v", err)
+	}
+	return chassis, client
+}
+
+func TestFetchRetriesLinkedEmptyCollections(t *testing.T) {
+	c := NewBaseCollector("ipmi", "fan", Options{EmptyRetries: 2, EmptyRetryDelay: time.Millisecond})
+
+	chassis, client := thermalChassis(t, `{"@odata.id": "/redfish/v1/Chassis/1/Thermal", "Fans": []}`)
+	if _, err := c.fetchThermal(chassis, "Fans"); err != nil {
+		t.Fatalf("fetchThermal failed: %v", err)
+	}
+	if reads := client.reads["/redfish/v1/Chassis/1/Thermal"]; reads != 3 {
+		t.Errorf("an empty fan collection was read %d times, want 3", reads)
+	}
+
+	chassis, client = thermalChassis(t, `{"@odata.id": "/redfish/v1/Chassis/1/Thermal", "Temperatures": []}`)
+	if _, err := c.fetchThermal(chassis, "Fans"); err != nil {
+		t.Fatalf("fetchThermal failed: %v", err)
+	}
+	if reads := client.reads["/redfish/v1/Chassis/1/Thermal"]; reads != 1 {
+		t.Errorf("a chassis without fans was read %d times, want 1", reads)
+	}
+}
+
+func TestFetchStopsRetryingOnceCancelled(t *testing.T) {
+	c := NewBaseCollector("ipmi", "fan", Options{EmptyRetries: 2, EmptyRetryDelay: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.SetContext(ctx)
+
+	chassis, client := thermalChassis(t, `{"@odata.id": "/redfish/v1/Chassis/1/Thermal", "Fans": []}`)
+	if _, err := c.fetchThermal(chassis, "Fans"); err != nil {
+		t.Fatalf("fetchThermal failed: %v", err)
+	}
+	if reads := client.reads["/redfish/v1/Chassis/1/Thermal"]; reads != 1 {
+		t.Errorf("the thermal resource was read %d times after the scrape was cancelled, want 1", reads)
+	}
+}
@@ -0,0 +1,153 @@
+package collector
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+)
+
+// Health schemes mapping a health status to a numeric gauge value
+const (
+	// HealthSchemeLegacy maps OK to 1, Warning and Critical to 0 and a missing status to 2
+	HealthSchemeLegacy = "legacy"
+
+	// HealthSchemeSeverity maps to an ordered severity: OK to 0, Warning to 1, Critical to 2 and unknown to 3
+	HealthSchemeSeverity = "severity"
+)
+
+// healthSchemeHelp describes the value mapping of each health scheme for the help text
+var healthSchemeHelp = map[string]string{
+	HealthSchemeLegacy:   " (1 = OK, 0 = Warning/Critical, 2 = Not Available)",
+	HealthSchemeSeverity: " (0 = OK, 1 = Warning, 2 = Critical, 3 = Unknown)",
+}
+
+// healthStates lists the states a health status is expanded into for state set metrics
+var healthStates = []string{"ok", "warning", "critical", "unknown"}
+
+// healthMetric describes a health status exposed as a numeric gauge and/or a state set, and
+// optionally the raw Redfish status it was mapped from
+type healthMetric struct {
+	numeric    *prometheus.Desc
+	stateSet   *prometheus.Desc
+	statusInfo *prometheus.Desc
+}
+
+// newHealthMetric creates the descriptors for a health status metric
+func (o Options) newHealthMetric(name, help string, labels []string) healthMetric {
+	stateLabels := append(append([]string{}, labels...), "state")
+	statusLabels := append(append([]string{}, labels...), "health", "state")
+
+	return healthMetric{
+		numeric: o.newDesc(
+			name,
+			help+o.healthHelp(),
+			labels,
+		),
+		stateSet: o.newDesc(
+			name+"_state",
+			help+" as a state set (1 for the current state, 0 otherwise)",
+			stateLabels,
+		),
+		statusInfo: o.newDesc(
+			strings.TrimSuffix(name, "_health")+"_status_info",
+			strings.TrimSuffix(help, " health status")+" health and state as reported by the BMC, always 1",
+			statusLabels,
+		),
+	}
+}
+
+// healthHelp describes the value mapping of the configured health scheme for the help text
+func (o Options) healthHelp() string {
+	scheme := o.healthScheme()
+	if o.HealthNotAvailable == nil {
+		return healthSchemeHelp[scheme]
+	}
+
+	value := strconv.FormatFloat(*o.HealthNotAvailable, 'g', -1, 64)
+	if scheme == HealthSchemeSeverity {
+		return " (0 = OK, 1 = Warning, 2 = Critical, 3 = Unknown, " + value + " = Not Available)"
+	}
+	return " (1 = OK, 0 = Warning/Critical, " + value + " = Not Available)"
+}
+
+// healthScheme returns the configured health scheme, defaulting to the legacy one
+func (o Options) healthScheme() string {
+	if o.HealthScheme == HealthSchemeSeverity {
+		return HealthSchemeSeverity
+	}
+	return HealthSchemeLegacy
+}
+
+// healthState maps a Redfish health status to its state set label value
+func healthState(health common.Health) string {
+	switch health {
+	case common.OKHealth:
+		return "ok"
+	case common.WarningHealth:
+		return "warning"
+	case common.CriticalHealth:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
+// DescribeHealth describes the enabled representations of a health metric
+func (c *BaseCollector) DescribeHealth(ch chan<- *prometheus.Desc, metric healthMetric) {
+	if !c.opts.DisableHealthGauges {
+		ch <- metric.numeric
+	}
+	if c.opts.HealthStateSet {
+		ch <- metric.stateSet
+	}
+	if c.opts.RawStates {
+		ch <- metric.statusInfo
+	}
+}
+
+// CollectHealth collects the enabled representations of a health metric
+func (c *BaseCollector) CollectHealth(ch chan<- prometheus.Metric, metric healthMetric, health common.Health, labelValues ...string) {
+	if !c.opts.DisableHealthGauges {
+		c.Emit(
+			ch,
+			metric.numeric,
+			prometheus.GaugeValue,
+			healthValue(health, c.opts.HealthScheme, c.opts.HealthNotAvailable),
+			labelValues...,
+		)
+	}
+
+	if c.opts.HealthStateSet {
+		current := healthState(health)
+		for _, state := range healthStates {
+			value := 0.0
+			if state == current {
+				value = 1.0
+			}
+			c.Emit(
+				ch,
+				metric.stateSet,
+				prometheus.GaugeValue,
+				value,
+				append(append([]string{}, labelValues...), state)...,
+			)
+		}
+	}
+}
+
+// CollectStatus collects the health metric of a component and, if enabled, its raw status
+func (c *BaseCollector) CollectStatus(ch chan<- prometheus.Metric, metric healthMetric, status common.Status, labelValues ...string) {
+	c.CollectHealth(ch, metric, status.Health, labelValues...)
+
+	if c.opts.RawStates {
+		c.Emit(
+			ch,
+			metric.statusInfo,
+			prometheus.GaugeValue,
+			1,
+			append(append([]string{}, labelValues...), string(status.Health), string(status.State))...,
+		)
+	}
+}
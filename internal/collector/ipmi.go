@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mllnd/sherlock/internal/ipmi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+)
+
+// IPMICollector collects basic sensor and power metrics over IPMI-over-LAN, for BMCs
+// without a Redfish service. It exposes the same metrics as the Redfish collectors.
+type IPMICollector struct {
+	BaseCollector
+	temperature       *prometheus.Desc
+	voltage           *prometheus.Desc
+	fanSpeed          *prometheus.Desc
+	powerState        *prometheus.Desc
+	temperatureHealth healthMetric
+	voltageHealth     healthMetric
+	fanHealth         healthMetric
+	sensors           []ipmi.Sensor
+	powerOn           *bool
+}
+
+// NewIPMICollector creates a new IPMICollector
+func NewIPMICollector(opts Options) *IPMICollector {
+	// Share the descriptors of the Redfish collectors, so both sources produce identical metrics
+	sensors := NewSensorCollector(opts)
+	fans := NewFansCollector(opts)
+	system := NewSystemCollector(opts)
+
+	return &IPMICollector{
+		BaseCollector:     NewBaseCollector("ipmi", "lan", opts),
+		temperature:       sensors.temperature,
+		voltage:           sensors.voltage,
+		fanSpeed:          fans.speed,
+		powerState:        system.powerState,
+		temperatureHealth: sensors.temperatureHealth,
+		voltageHealth:     sensors.voltageHealth,
+		fanHealth:         fans.health,
+	}
+}
+
+// Update reads the sensors and power state over IPMI
+func (c *IPMICollector) Update(ctx context.Context, client *ipmi.Client) error {
+	sensors, err := client.Sensors(ctx)
+	if err != nil {
+		return err
+	}
+
+	powerOn, err := client.PowerOn(ctx)
+	if err != nil {
+		c.logger.Debug("failed to get power state over ipmi", "error", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.sensors = c.sensors[:0]
+	for _, sensor := range sensors {
+		if c.opts.keepSensor(sensor.Name) {
+			c.sensors = append(c.sensors, sensor)
+		}
+	}
+	c.powerOn = nil
+	if err == nil {
+		c.powerOn = &powerOn
+	}
+
+	return nil
+}
+
+// ipmiHealth maps an ipmitool sensor status to a Redfish health status
+func ipmiHealth(status string) common.Health {
+	switch status {
+	case "ok":
+		return common.OKHealth
+	case "nc":
+		return common.WarningHealth
+	case "cr", "nr":
+		return common.CriticalHealth
+	default:
+		return ""
+	}
+}
+
+// Describe describes all metrics this collector exposes
+func (c *IPMICollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.temperature
+	ch <- c.voltage
+	ch <- c.fanSpeed
+	ch <- c.powerState
+	c.DescribeHealth(ch, c.temperatureHealth)
+	c.DescribeHealth(ch, c.voltageHealth)
+	c.DescribeHealth(ch, c.fanHealth)
+}
+
+// Collect collects all metrics
+func (c *IPMICollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, sensor := range c.sensors {
+		health := ipmiHealth(sensor.Status)
+
+		switch unit := strings.ToLower(sensor.Unit); {
+		case strings.Contains(unit, "degrees c"), strings.Contains(unit, "degrees f"):
+			value := sensor.Value
+			if strings.Contains(unit, "degrees f") {
+				value = fahrenheitToCelsius(value)
+			}
+			c.Emit(
+				ch,
+				c.temperature,
+				prometheus.GaugeValue,
+				value,
+				c.componentValues("", sensor.Name)...,
+			)
+			c.CollectHealth(ch, c.temperatureHealth, health, c.componentValues("", sensor.Name)...)
+		case unit == "volts":
+			c.Emit(
+				ch,
+				c.voltage,
+				prometheus.GaugeValue,
+				sensor.Value,
+				c.componentValues("", sensor.Name)...,
+			)
+			c.CollectHealth(ch, c.voltageHealth, health, c.componentValues("", sensor.Name)...)
+		case unit == "rpm":
+			c.Emit(
+				ch,
+				c.fanSpeed,
+				prometheus.GaugeValue,
+				sensor.Value,
+				c.componentValues("", sensor.Name)...,
+			)
+			c.CollectHealth(ch, c.fanHealth, health, c.componentValues("", sensor.Name)...)
+		}
+	}
+
+	if c.powerOn != nil {
+		powerState := 0.0
+		if *c.powerOn {
+			powerState = 1.0
+		}
+		c.Emit(
+			ch,
+			c.powerState,
+			prometheus.GaugeValue,
+			powerState,
+		)
+	}
+}
@@ -0,0 +1,345 @@
+package collector
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mllnd/sherlock/internal/redfish"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+// ManagerCollector collects metrics about the BMC itself
+type ManagerCollector struct {
+	BaseCollector
+	health       healthMetric
+	state        *prometheus.Desc
+	firmwareInfo *prometheus.Desc
+	nicLinkUp    *prometheus.Desc
+	nicInfo      *prometheus.Desc
+	cpuUsage     *prometheus.Desc
+	memoryUsage  *prometheus.Desc
+	uptime       *prometheus.Desc
+	managers     map[string]managerReading
+	nics         map[string]nicReading
+}
+
+type managerReading struct {
+	status  common.Status
+	state   float64
+	id      string
+	version string
+	usage   managerUsage
+
+	// Time since the last BMC reset, if the BMC reports it
+	uptime        float64
+	uptimePresent bool
+}
+
+// managerUsage holds the BMC's own CPU and memory utilization as reported
+// in its diagnostic data
+type managerUsage struct {
+	cpu           float64
+	cpuPresent    bool
+	memory        float64
+	memoryPresent bool
+}
+
+type nicReading struct {
+	linkUp        float64
+	linkPresent   bool
+	managerID     string
+	name          string
+	macAddress    string
+	ipv4Addresses string
+}
+
+// NewManagerCollector creates a new ManagerCollector
+func NewManagerCollector(opts Options) *ManagerCollector {
+	return &ManagerCollector{
+		BaseCollector: NewBaseCollector("ipmi", "bmc", opts),
+		health: opts.newHealthMetric(
+			"ipmi_bmc_health",
+			"BMC health status",
+			[]string{"manager_id"},
+		),
+		state: opts.newDesc(
+			"ipmi_bmc_state",
+			"BMC operating state (1 = Enabled, 0 = Disabled)",
+			[]string{"manager_id"},
+		),
+		firmwareInfo: opts.newDesc(
+			"ipmi_bmc_firmware_info",
+			"BMC firmware version, always 1",
+			[]string{"manager_id", "version"},
+		),
+		nicLinkUp: opts.newDesc(
+			"ipmi_bmc_nic_link_up",
+			"BMC management network interface link state (1 = LinkUp, 0 = LinkDown/NoLink)",
+			[]string{"manager_id", "interface"},
+		),
+		nicInfo: opts.newDesc(
+			"ipmi_bmc_nic_info",
+			"BMC management network interface MAC and IPv4 addresses, always 1",
+			[]string{"manager_id", "interface", "mac_address", "ipv4_addresses"},
+		),
+		cpuUsage: opts.newDesc(
+			"ipmi_bmc_cpu_utilization_percent",
+			"BMC processor utilization in percent (kernel + user)",
+			[]string{"manager_id"},
+		),
+		memoryUsage: opts.newDesc(
+			"ipmi_bmc_memory_utilization_percent",
+			"BMC memory utilization in percent of total memory",
+			[]string{"manager_id"},
+		),
+		uptime: opts.newDesc(
+			"ipmi_bmc_uptime_seconds",
+			"Time since the last reset of the BMC in seconds",
+			[]string{"manager_id"},
+		),
+		managers: make(map[string]managerReading),
+		nics:     make(map[string]nicReading),
+	}
+}
+
+// Update fetches new metrics and updates the prometheus metrics
+func (c *ManagerCollector) Update(client *redfish.Client) error {
+	start := time.Now()
+	defer c.RecordScrapeTime(start)
+
+	// Clear previous readings
+	c.mutex.Lock()
+	c.managers = make(map[string]managerReading)
+	c.nics = make(map[string]nicReading)
+	c.mutex.Unlock()
+
+	managers, err := client.GetManagers()
+	if err != nil {
+		c.logger.Debug("failed to get managers", "error", err)
+		return nil
+	}
+
+	usage := make(map[string]managerUsage)
+	for _, manager := range managers {
+		c.processInterfaces(manager)
+		usage[manager.ID] = c.managerUsage(manager)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, manager := range managers {
+		state := 0.0
+		if manager.Status.State == common.EnabledState {
+			state = 1.0
+		}
+
+		uptime, uptimePresent := managerUptime(manager)
+
+		c.managers[manager.ID] = managerReading{
+			status:        manager.Status,
+			state:         state,
+			id:            manager.ID,
+			version:       manager.FirmwareVersion,
+			usage:         usage[manager.ID],
+			uptime:        uptime,
+			uptimePresent: uptimePresent,
+		}
+	}
+
+	return nil
+}
+
+// processInterfaces stores the link state and addresses of a manager's network interfaces
+func (c *ManagerCollector) processInterfaces(manager *gofishredfish.Manager) {
+	interfaces, err := manager.EthernetInterfaces()
+	if err != nil {
+		c.logger.Debug("failed to get manager network interfaces", "manager", manager.ID, "error", err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, iface := range interfaces {
+		var addresses []string
+		for _, address := range iface.IPv4Addresses {
+			if address.Address != "" {
+				addresses = append(addresses, address.Address)
+			}
+		}
+
+		linkUp := 0.0
+		if iface.LinkStatus == gofishredfish.LinkUpLinkStatus {
+			linkUp = 1.0
+		}
+
+		c.nics[manager.ID+"/"+iface.ID] = nicReading{
+			linkUp:        linkUp,
+			linkPresent:   iface.LinkStatus != "",
+			managerID:     manager.ID,
+			name:          iface.ID,
+			macAddress:    iface.MACAddress,
+			ipv4Addresses: strings.Join(addresses, ","),
+		}
+	}
+}
+
+// managerUsage reads the BMC's CPU and memory utilization from its diagnostic
+// data. Managers that don't implement ManagerDiagnosticData report nothing.
+func (c *ManagerCollector) managerUsage(manager *gofishredfish.Manager) managerUsage {
+	var usage managerUsage
+
+	// Read the diagnostic data through a raw client to tell an idle BMC from one without statistics
+	client := manager.GetClient()
+	defer manager.SetClient(client)
+	raw := newRawClient(client)
+	manager.SetClient(raw)
+
+	data, err := manager.ManagerDiagnosticData()
+	if err != nil || data == nil {
+		c.logger.Debug("failed to get manager diagnostic data", "manager", manager.ID, "error", err)
+		return usage
+	}
+
+	processor := data.ProcessorStatistics
+	if raw.has(data.ODataID, "ProcessorStatistics", "KernelPercent") || raw.has(data.ODataID, "ProcessorStatistics", "UserPercent") {
+		usage.cpu = processor.KernelPercent + processor.UserPercent
+		usage.cpuPresent = true
+	}
+
+	// Prefer the used memory the BMC reports, and only derive it from the available memory when
+	// that is reported, since a missing value would read as full memory
+	memory := data.MemoryStatistics
+	if memory.TotalBytes > 0 {
+		switch {
+		case raw.has(data.ODataID, "MemoryStatistics", "UsedBytes"):
+			usage.memory = float64(memory.UsedBytes) / float64(memory.TotalBytes) * 100
+			usage.memoryPresent = true
+		case raw.has(data.ODataID, "MemoryStatistics", "AvailableBytes"):
+			used := memory.TotalBytes - memory.AvailableBytes
+			usage.memory = float64(used) / float64(memory.TotalBytes) * 100
+			usage.memoryPresent = true
+		}
+	}
+
+	return usage
+}
+
+// managerUptime returns the time since the last reset of a manager. It is measured against the
+// manager's own clock when reported, so that clock skew between the BMC and the exporter cancels out.
+func managerUptime(manager *gofishredfish.Manager) (float64, bool) {
+	lastReset, err := time.Parse(time.RFC3339, manager.LastResetTime)
+	if err != nil {
+		return 0, false
+	}
+
+	now := time.Now()
+	if bmcTime, err := time.Parse(time.RFC3339, manager.DateTime); err == nil {
+		now = bmcTime
+	}
+
+	uptime := now.Sub(lastReset)
+	if uptime < 0 {
+		return 0, false
+	}
+	return uptime.Seconds(), true
+}
+
+// Describe describes all metrics this collector exposes
+func (c *ManagerCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.DescribeHealth(ch, c.health)
+	ch <- c.state
+	ch <- c.firmwareInfo
+	ch <- c.nicLinkUp
+	ch <- c.nicInfo
+	ch <- c.cpuUsage
+	ch <- c.memoryUsage
+	ch <- c.uptime
+	c.DescribeScrapeTime(ch)
+}
+
+// Collect collects all metrics
+func (c *ManagerCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, reading := range c.managers {
+		c.CollectStatus(ch, c.health, reading.status, reading.id)
+
+		c.Emit(
+			ch,
+			c.state,
+			prometheus.GaugeValue,
+			reading.state,
+			reading.id,
+		)
+
+		c.Emit(
+			ch,
+			c.firmwareInfo,
+			prometheus.GaugeValue,
+			1,
+			reading.id,
+			reading.version,
+		)
+
+		if reading.usage.cpuPresent {
+			c.Emit(
+				ch,
+				c.cpuUsage,
+				prometheus.GaugeValue,
+				reading.usage.cpu,
+				reading.id,
+			)
+		}
+
+		if reading.usage.memoryPresent {
+			c.Emit(
+				ch,
+				c.memoryUsage,
+				prometheus.GaugeValue,
+				reading.usage.memory,
+				reading.id,
+			)
+		}
+
+		if reading.uptimePresent {
+			c.Emit(
+				ch,
+				c.uptime,
+				prometheus.GaugeValue,
+				reading.uptime,
+				reading.id,
+			)
+		}
+	}
+
+	for _, nic := range c.nics {
+		if nic.linkPresent {
+			c.Emit(
+				ch,
+				c.nicLinkUp,
+				prometheus.GaugeValue,
+				nic.linkUp,
+				nic.managerID,
+				nic.name,
+			)
+		}
+
+		c.Emit(
+			ch,
+			c.nicInfo,
+			prometheus.GaugeValue,
+			1,
+			nic.managerID,
+			nic.name,
+			nic.macAddress,
+			nic.ipv4Addresses,
+		)
+	}
+
+	c.CollectScrapeTime(ch)
+}
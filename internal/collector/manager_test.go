@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"testing"
+
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+func TestManagerUsageOfIdleBMC(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		diagnostic string
+		present    bool
+	}{
+		{"idle", `{"ProcessorStatistics": {"KernelPercent": 0, "UserPercent": 0}}`, true},
+		{"without statistics", `{"MemoryStatistics": {"TotalBytes": 1024, "AvailableBytes": 512}}`, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeClient{resources: map[string]string{
+				"/redfish/v1/Managers/bmc": `{
+					"@odata.id": "/redfish/v1/Managers/bmc",
+					"Id": "bmc",
+					"ManagerDiagnosticData": {"@odata.id": "/redfish/v1/Managers/bmc/ManagerDiagnosticData"}
+				}`,
+				"/redfish/v1/Managers/bmc/ManagerDiagnosticData": `{"@odata.id": "/redfish/v1/Managers/bmc/ManagerDiagnosticData", ` + tt.diagnostic[1:],
+			}}
+			manager, err := gofishredfish.GetManager(client, "/redfish/v1/Managers/bmc")
+			if err != nil {
+				t.Fatalf("failed to read the manager: %v", err)
+			}
+
+			usage := NewManagerCollector(Options{}).managerUsage(manager)
+			if usage.cpuPresent != tt.present || usage.cpu != 0 {
+				t.Errorf("got CPU usage %v (present %v), want present %v", usage.cpu, usage.cpuPresent, tt.present)
+			}
+		})
+	}
+}
+
+func TestManagerMemoryUsage(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		memory  string
+		present bool
+		want    float64
+	}{
+		{"used", `{"TotalBytes": 1000, "UsedBytes": 250, "AvailableBytes": 500}`, true, 25},
+		{"available", `{"TotalBytes": 1000, "AvailableBytes": 600}`, true, 40},
+		{"total only", `{"TotalBytes": 1000}`, false, 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeClient{resources: map[string]string{
+				"/redfish/v1/Managers/bmc": `{
+					"@odata.id": "/redfish/v1/Managers/bmc",
+					"Id": "bmc",
+					"ManagerDiagnosticData": {"@odata.id": "/redfish/v1/Managers/bmc/ManagerDiagnosticData"}
+				}`,
+				"/redfish/v1/Managers/bmc/ManagerDiagnosticData": `{
+					"@odata.id": "/redfish/v1/Managers/bmc/ManagerDiagnosticData",
+					"MemoryStatistics": ` + tt.memory + `
+				}`,
+			}}
+			manager, err := gofishredfish.GetManager(client, "/redfish/v1/Managers/bmc")
+			if err != nil {
+				t.Fatalf("failed to read the manager: %v", err)
+			}
+
+			usage := NewManagerCollector(Options{}).managerUsage(manager)
+			if usage.memoryPresent != tt.present || usage.memory != tt.want {
+				t.Errorf("got memory usage %v (present %v), want %v (present %v)", usage.memory, usage.memoryPresent, tt.want, tt.present)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package collector
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mllnd/sherlock/internal/redfish"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+)
+
+// MemoryCollector collects per-module memory metrics
+type MemoryCollector struct {
+	BaseCollector
+	correctableErrors   *prometheus.Desc
+	uncorrectableErrors *prometheus.Desc
+	counterBase         *prometheus.Desc
+	temperature         *prometheus.Desc
+	modules             map[string]memoryModule
+	sessionStart        time.Time
+}
+
+type memoryModule struct {
+	correctable        float64
+	uncorrectable      float64
+	temperature        float64
+	temperaturePresent bool
+	name               string
+	odataID            string
+}
+
+// NewMemoryCollector creates a new MemoryCollector
+func NewMemoryCollector(opts Options) *MemoryCollector {
+	return &MemoryCollector{
+		BaseCollector: NewBaseCollector("ipmi", "memory", opts),
+		correctableErrors: opts.newDesc(
+			"ipmi_memory_correctable_ecc_errors_total",
+			"Lifetime number of correctable ECC errors reported by the memory module",
+			opts.componentLabels("name"),
+		),
+		uncorrectableErrors: opts.newDesc(
+			"ipmi_memory_uncorrectable_ecc_errors_total",
+			"Lifetime number of uncorrectable ECC errors reported by the memory module",
+			opts.componentLabels("name"),
+		),
+		counterBase: opts.newDesc(
+			"ipmi_memory_ecc_errors_counter_base",
+			"Unix timestamp of the Redfish session the ECC error counters were read in, changes when counters may have been reset",
+			nil,
+		),
+		temperature: opts.newDesc(
+			"ipmi_memory_module_temperature_celsius",
+			"Memory module temperature in degree Celsius",
+			opts.componentLabels("name"),
+		),
+		modules: make(map[string]memoryModule),
+	}
+}
+
+// Update fetches new metrics and updates the prometheus metrics
+func (c *MemoryCollector) Update(client *redfish.Client) error {
+	start := time.Now()
+	defer c.RecordScrapeTime(start)
+
+	// Clear previous readings
+	c.mutex.Lock()
+	c.modules = make(map[string]memoryModule)
+	c.mutex.Unlock()
+
+	// Get all systems
+	systems, err := client.GetSystems()
+	if err != nil || len(systems) == 0 {
+		c.logger.Debug("failed to get systems", "error", err)
+		return nil
+	}
+
+	memory, err := systems[0].Memory()
+	if err != nil {
+		c.logger.Debug("failed to get memory modules", "error", err)
+		return c.unsupported(err)
+	}
+
+	sessionStart := client.ConnectedAt()
+	raw := newRawClient(systems[0].GetClient())
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.sessionStart = sessionStart
+
+	for _, module := range memory {
+		// Skip empty slots
+		if module.Status.State == "Absent" {
+			continue
+		}
+
+		metrics, err := module.Metrics()
+		if err != nil {
+			c.logger.Debug("failed to get memory metrics", "module", module.ID, "error", err)
+			continue
+		}
+
+		name := module.DeviceLocator
+		if name == "" {
+			name = module.ID
+		}
+
+		reading := memoryModule{
+			correctable:   float64(metrics.LifeTime.CorrectableECCErrorCount),
+			uncorrectable: float64(metrics.LifeTime.UncorrectableECCErrorCount),
+			name:          name,
+			odataID:       module.ODataID,
+		}
+		module.SetClient(raw)
+		if environment, err := module.EnvironmentMetrics(); err != nil {
+			c.logger.Debug("failed to get memory environment metrics", "module", module.ID, "error", err)
+		} else if environment != nil && raw.has(environment.ODataID, "TemperatureCelsius", "Reading") {
+			reading.temperature = float64(environment.TemperatureCelsius.Reading)
+			reading.temperaturePresent = true
+		}
+
+		c.modules[module.ID] = reading
+	}
+
+	c.matchTemperatureSensors(client)
+
+	return nil
+}
+
+// matchTemperatureSensors fills in the temperature of modules without a reading of their own
+// from the thermal sensors of the main chassis named after their locator, e.g. "DIMM A1 Temp"
+// for the module in slot "DIMM_A1". The caller must hold the mutex.
+func (c *MemoryCollector) matchTemperatureSensors(client *redfish.Client) {
+	missing := false
+	for _, module := range c.modules {
+		missing = missing || !module.temperaturePresent
+	}
+	if !missing {
+		return
+	}
+
+	chassis, err := client.GetMainChassis()
+	if err != nil {
+		c.logger.Debug("failed to get main chassis", "error", err)
+		return
+	}
+	thermal, err := chassis.Thermal()
+	if err != nil || thermal == nil {
+		c.logger.Debug("failed to get thermal information", "error", err)
+		return
+	}
+
+	for id, module := range c.modules {
+		if module.temperaturePresent {
+			continue
+		}
+		for _, sensor := range thermal.Temperatures {
+			if sensor.Status.State != common.AbsentState && namesLocator(sensor.Name, module.name) {
+				module.temperature = float64(sensor.ReadingCelsius)
+				module.temperaturePresent = true
+				c.modules[id] = module
+				break
+			}
+		}
+	}
+}
+
+// namesLocator reports whether a sensor name refers to the given slot locator, ignoring case,
+// spaces and punctuation. The locator must not be followed by a digit, so that "A1" doesn't
+// match "DIMM A10 Temp".
+func namesLocator(sensorName, locator string) bool {
+	name, key := locatorKey(sensorName), locatorKey(locator)
+	if key == "" {
+		return false
+	}
+
+	for offset := 0; ; {
+		index := strings.Index(name[offset:], key)
+		if index < 0 {
+			return false
+		}
+		end := offset + index + len(key)
+		if end == len(name) || !unicode.IsDigit(rune(name[end])) {
+			return true
+		}
+		offset += index + 1
+	}
+}
+
+// locatorKey lowercases a name and drops everything but letters and digits
+func locatorKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// Describe describes all metrics this collector exposes
+func (c *MemoryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.correctableErrors
+	ch <- c.uncorrectableErrors
+	ch <- c.counterBase
+	ch <- c.temperature
+	c.DescribeScrapeTime(ch)
+}
+
+// Collect collects all metrics
+func (c *MemoryCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, module := range c.modules {
+		c.Emit(
+			ch,
+			c.correctableErrors,
+			prometheus.CounterValue,
+			module.correctable,
+			c.componentValues(module.odataID, module.name)...,
+		)
+
+		c.Emit(
+			ch,
+			c.uncorrectableErrors,
+			prometheus.CounterValue,
+			module.uncorrectable,
+			c.componentValues(module.odataID, module.name)...,
+		)
+
+		if module.temperaturePresent {
+			c.Emit(
+				ch,
+				c.temperature,
+				prometheus.GaugeValue,
+				module.temperature,
+				c.componentValues(module.odataID, module.name)...,
+			)
+		}
+	}
+
+	if len(c.modules) > 0 {
+		c.Emit(
+			ch,
+			c.counterBase,
+			prometheus.GaugeValue,
+			float64(c.sessionStart.Unix()),
+		)
+	}
+
+	c.CollectScrapeTime(ch)
+}
@@ -0,0 +1,160 @@
+package collector
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options holds the settings shared by all collectors
+type Options struct {
+	// SensorInclude, when set, only keeps sensors and fans with a matching name
+	SensorInclude *regexp.Regexp
+
+	// SensorExclude, when set, drops sensors and fans with a matching name
+	SensorExclude *regexp.Regexp
+
+	// AllChassis merges sensor, fan and power readings from every chassis instead of the main one
+	AllChassis bool
+
+	// ChassisWorkers limits how many chassis are read concurrently when AllChassis is set
+	ChassisWorkers int
+
+	// PSUSyntheticNames labels power supplies as "PSU N" by iteration order instead of by their name
+	PSUSyntheticNames bool
+
+	// MaxLabelLength truncates string label values to this many characters (0 = no limit)
+	MaxLabelLength int
+
+	// MaxSeries limits the series each collector emits per scrape (0 = no limit)
+	MaxSeries int
+
+	// CriticalEventsWindow is how far back critical log entries are counted (0 = disabled)
+	CriticalEventsWindow time.Duration
+
+	// ScrapeDurationDecimals rounds the scrape durations to this many decimal places of a second
+	// (negative = full precision)
+	ScrapeDurationDecimals int
+
+	// ScrapeDurationMilliseconds additionally exposes the scrape durations in milliseconds
+	ScrapeDurationMilliseconds bool
+
+	// StaleScrapes flags a sensor as stale once its reading is unchanged for this many scrape
+	// intervals (0 = disabled)
+	StaleScrapes int
+
+	// ScrapeInterval is the expected interval between scrapes of a target
+	ScrapeInterval time.Duration
+
+	// ChangesOnly exposes gauge readings that changed by at most ChangeEpsilon with the value and
+	// timestamp they were last exposed with, for up to ChangeMaxAge, so that Prometheus doesn't
+	// store them again
+	ChangesOnly   bool
+	ChangeEpsilon float64
+	ChangeMaxAge  time.Duration
+
+	// TemperatureRange and VoltageRange bound plausible sensor readings. Readings outside of them,
+	// typically sentinels such as -128 for "no reading", are not exported.
+	TemperatureRange Range
+	VoltageRange     Range
+
+	// Chassis selects the chassis used by each chassis-based collector (by subsystem): a chassis ID,
+	// "main" for the main chassis (default) or "auto" for the main chassis falling back to the first one
+	Chassis map[string]string
+
+	// EmitZero lists the collectors (by subsystem) that report explicit zero readings for
+	// present components
+	EmitZero map[string]bool
+
+	// EmptyRetries is how often a thermal or power fetch is retried when it succeeds without readings
+	EmptyRetries int
+
+	// EmptyRetryDelay is the delay before retrying an empty fetch
+	EmptyRetryDelay time.Duration
+
+	// MetricHelp overrides the help text of metrics by metric name
+	MetricHelp map[string]string
+
+	// HealthScheme selects how health statuses map to numeric gauge values (legacy or severity)
+	HealthScheme string
+
+	// HealthNotAvailable, when set, is the numeric health value of components without a reported
+	// status instead of the scheme's default (e.g. NaN so that aggregations skip them)
+	HealthNotAvailable *float64
+
+	// HealthStateSet additionally exposes health as one series per possible state
+	HealthStateSet bool
+
+	// DisableHealthGauges drops the numeric health gauges
+	DisableHealthGauges bool
+
+	// RawStates additionally exposes the health and state strings of components as reported by the BMC
+	RawStates bool
+
+	// ODataIDLabel adds an odata_id label with the Redfish resource path to per-component metrics
+	ODataIDLabel bool
+}
+
+// Range is a plausible range of readings, the zero value accepting every reading
+type Range struct {
+	Min float64
+	Max float64
+}
+
+// plausible reports whether a reading lies within the range
+func (r Range) plausible(value float64) bool {
+	if r.Min == 0 && r.Max == 0 {
+		return true
+	}
+	return value >= r.Min && value <= r.Max
+}
+
+// keepSensor reports whether a sensor or fan with the given name passes the name filters
+func (o Options) keepSensor(name string) bool {
+	if o.SensorInclude != nil && !o.SensorInclude.MatchString(name) {
+		return false
+	}
+	if o.SensorExclude != nil && o.SensorExclude.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// descs caches metric descriptors by name, help text and labels. Collectors are created for
+// every scrape, but descriptors are immutable and can be shared between them.
+var descs sync.Map
+
+// newDesc returns the descriptor of a metric, applying any help text override for the metric
+func (o Options) newDesc(name, help string, labels []string) *prometheus.Desc {
+	if override, ok := o.MetricHelp[name]; ok {
+		help = override
+	}
+
+	key := name + "\x00" + help + "\x00" + strings.Join(labels, "\x00")
+	if desc, ok := descs.Load(key); ok {
+		return desc.(*prometheus.Desc)
+	}
+	desc, _ := descs.LoadOrStore(key, prometheus.NewDesc(name, help, labels, nil))
+	return desc.(*prometheus.Desc)
+}
+
+// componentLabels returns the label names of a per-component metric, adding odata_id if enabled
+func (o Options) componentLabels(labels ...string) []string {
+	if o.ODataIDLabel {
+		labels = append(labels, "odata_id")
+	}
+	return labels
+}
+
+// chassisComponentLabels returns the label names of a per-component metric that may be merged
+// from every chassis, adding chassis if AllChassis is set so that same-named components of
+// different chassis stay apart
+func (o Options) chassisComponentLabels(labels ...string) []string {
+	if o.AllChassis {
+		labels = append(labels, "chassis")
+	}
+	return o.componentLabels(labels...)
+}
@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func BenchmarkNewDesc(b *testing.B) {
+	opts := Options{ODataIDLabel: true}
+	labels := opts.componentLabels("name")
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			opts.newDesc("ipmi_fan_speed_rpm", "Fan speed in RPM", labels)
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			prometheus.NewDesc("ipmi_fan_speed_rpm", "Fan speed in RPM", labels, nil)
+		}
+	})
+}
+
+func BenchmarkEmitChangesOnly(b *testing.B) {
+	c := NewBaseCollector("ipmi", "fan", Options{ChangesOnly: true, ChangeMaxAge: time.Hour})
+	desc := c.opts.newDesc("ipmi_fan_speed_rpm", "Fan speed in RPM", []string{"name"})
+	ch := make(chan prometheus.Metric, 1)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.Emit(ch, desc, prometheus.GaugeValue, 4200, "Fan 1")
+		<-ch
+	}
+}
@@ -2,51 +2,200 @@ package collector
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/mllnd/sherlock/internal/redfish"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
 )
 
 // PowerCollector collects power supply metrics
 type PowerCollector struct {
 	BaseCollector
-	psuHealth       *prometheus.Desc
+	psuHealth       healthMetric
 	psuACInputPower *prometheus.Desc
 	psuDCPower      *prometheus.Desc
+	psuFrequency    *prometheus.Desc
+	psuInputAmps    *prometheus.Desc
+	psuOutputAmps   *prometheus.Desc
+	psuCount        *prometheus.Desc
 	readings        map[string]psuReading
+
+	// Rolled-up health of the power subsystem
+	powerHealth      healthMetric
+	subsystemHealth  common.Health
+	subsystemPresent bool
+
+	// Rack PDUs listed in the power equipment of the service
+	pduOutletPower  *prometheus.Desc
+	pduInputPower   *prometheus.Desc
+	pduInputVoltage *prometheus.Desc
+	pduOutlets      map[string]pduReading
+	pduMains        map[string]pduReading
+
+	// Chassis passed over while searching for power supplies, by ID with the reason
+	skippedDesc *prometheus.Desc
+	skipped     map[string]string
 }
 
+// Reasons a chassis is skipped while searching for power supplies
+const (
+	skipKnownProblematic = "known_problematic"
+	skipNoPowerData      = "no_power_data"
+	skipNoPowerSupplies  = "no_power_supplies"
+)
+
 type psuReading struct {
-	health  float64
+	status  common.Status
 	acPower float64
 	dcPower float64
 	name    string
+	chassis string
+	odataID string
+	psuUnitMetrics
+}
+
+// psuUnitMetrics holds the readings only available from the PowerSubsystem metrics of a power supply
+type psuUnitMetrics struct {
+	frequency           float64
+	frequencyPresent    bool
+	inputCurrent        float64
+	inputCurrentPresent bool
+	railCurrents        map[string]float64
+}
+
+// psuMetricsIndex holds the PowerSubsystem metrics of the power supplies of a chassis by serial
+// number and by name, which is how they are matched with the power supplies of the legacy Power
+// resource. A nil entry marks a key shared by several power supplies, whose metrics can't be
+// attributed.
+type psuMetricsIndex struct {
+	bySerial map[string]*psuUnitMetrics
+	byName   map[string]*psuUnitMetrics
+}
+
+// add indexes the metrics of a power supply
+func (i *psuMetricsIndex) add(serial, name string, metrics psuUnitMetrics) {
+	if i.bySerial == nil {
+		i.bySerial = make(map[string]*psuUnitMetrics)
+		i.byName = make(map[string]*psuUnitMetrics)
+	}
+	addUnique(i.bySerial, serial, &metrics)
+	addUnique(i.byName, name, &metrics)
+}
+
+// addUnique adds metrics to an index under a non-empty key, marking keys added twice as shared
+func addUnique(index map[string]*psuUnitMetrics, key string, metrics *psuUnitMetrics) {
+	if key == "" {
+		return
+	}
+	if _, ok := index[key]; ok {
+		metrics = nil
+	}
+	index[key] = metrics
+}
+
+// lookup returns the metrics of a power supply of the legacy Power resource. Power supplies are
+// matched by serial number when both resources report one, and otherwise by a name that no other
+// power supply of either resource shares.
+func (i psuMetricsIndex) lookup(psu gofishredfish.PowerSupply, uniqueName bool) psuUnitMetrics {
+	if psu.SerialNumber != "" && len(i.bySerial) > 0 {
+		if metrics := i.bySerial[psu.SerialNumber]; metrics != nil {
+			return *metrics
+		}
+		return psuUnitMetrics{}
+	}
+	if metrics := i.byName[psu.Name]; uniqueName && metrics != nil {
+		return *metrics
+	}
+	return psuUnitMetrics{}
+}
+
+// psuMetricsReadings are the readings of the PowerSubsystem metrics of a power supply, decoded
+// with pointers since gofish decodes absent readings as 0
+type psuMetricsReadings struct {
+	FrequencyHz      struct{ Reading *float64 }
+	InputCurrentAmps struct{ Reading *float64 }
+	RailCurrentAmps  []struct{ Reading *float64 }
+}
+
+// pduReading holds the readings of a PDU outlet or mains circuit
+type pduReading struct {
+	pdu     string
+	name    string
+	power   float64
+	voltage float64
 }
 
 // NewPowerCollector creates a new PowerCollector
-func NewPowerCollector() *PowerCollector {
+func NewPowerCollector(opts Options) *PowerCollector {
 	return &PowerCollector{
-		BaseCollector: NewBaseCollector("ipmi", "power"),
-		psuHealth: prometheus.NewDesc(
+		BaseCollector: NewBaseCollector("ipmi", "power", opts),
+		psuHealth: opts.newHealthMetric(
 			"ipmi_psu_health",
-			"Power supply health status (1 = OK, 0 = Warning/Critical, 2 = Not Available)",
-			[]string{"name"},
-			nil,
+			"Power supply health status",
+			opts.chassisComponentLabels("name"),
 		),
-		psuACInputPower: prometheus.NewDesc(
+		psuACInputPower: opts.newDesc(
 			"ipmi_psu_ac_input_power_watts",
 			"Power supply AC input power in watts",
-			[]string{"name"},
-			nil,
+			opts.chassisComponentLabels("name"),
 		),
-		psuDCPower: prometheus.NewDesc(
+		psuDCPower: opts.newDesc(
 			"ipmi_psu_dc_output_power_watts",
 			"Power supply DC output power in watts",
-			[]string{"name"},
+			opts.chassisComponentLabels("name"),
+		),
+		psuFrequency: opts.newDesc(
+			"ipmi_psu_input_frequency_hz",
+			"Power supply input line frequency in hertz",
+			opts.chassisComponentLabels("name"),
+		),
+		psuInputAmps: opts.newDesc(
+			"ipmi_psu_input_current_amps",
+			"Power supply input current in amperes",
+			opts.chassisComponentLabels("name"),
+		),
+		psuOutputAmps: opts.newDesc(
+			"ipmi_psu_output_current_amps",
+			"Power supply output current of a DC rail in amperes",
+			opts.chassisComponentLabels("name", "rail"),
+		),
+		psuCount: opts.newDesc(
+			"ipmi_psu_count",
+			"Number of power supplies reported by the BMC",
 			nil,
 		),
-		readings: make(map[string]psuReading),
+		powerHealth: opts.newHealthMetric(
+			"ipmi_power_subsystem_health",
+			"Power subsystem health status",
+			nil,
+		),
+		pduOutletPower: opts.newDesc(
+			"ipmi_pdu_outlet_power_watts",
+			"Rack PDU outlet power in watts",
+			[]string{"pdu", "outlet"},
+		),
+		pduInputPower: opts.newDesc(
+			"ipmi_pdu_input_power_watts",
+			"Rack PDU mains input power in watts",
+			[]string{"pdu", "mains"},
+		),
+		pduInputVoltage: opts.newDesc(
+			"ipmi_pdu_input_voltage_volts",
+			"Rack PDU mains input voltage in volts",
+			[]string{"pdu", "mains"},
+		),
+		skippedDesc: opts.newDesc(
+			"ipmi_chassis_skipped",
+			"Chassis skipped while searching for power supplies, labeled by the reason, always 1",
+			[]string{"id", "reason"},
+		),
+		readings:   make(map[string]psuReading),
+		pduOutlets: make(map[string]pduReading),
+		pduMains:   make(map[string]pduReading),
+		skipped:    make(map[string]string),
 	}
 }
 
@@ -58,8 +207,53 @@ func (c *PowerCollector) Update(client *redfish.Client) error {
 	// Clear previous readings first to ensure we don't have stale data
 	c.mutex.Lock()
 	c.readings = make(map[string]psuReading)
+	c.subsystemHealth = ""
+	c.subsystemPresent = false
+	c.pduOutlets = make(map[string]pduReading)
+	c.pduMains = make(map[string]pduReading)
+	c.skipped = make(map[string]string)
 	c.mutex.Unlock()
 
+	err := c.updatePowerSupplies(client)
+
+	// Rack PDUs belong to the service rather than to a chassis. Services that only expose PDUs
+	// have no power supplies, which is not an error then.
+	if c.chassisScope() == "" && c.collectPDUs(client) > 0 && err != nil {
+		c.logger.Debug("no power supplies found, using rack PDU readings only", "error", err)
+		return nil
+	}
+
+	return err
+}
+
+// updatePowerSupplies stores the power supply readings of the requested chassis
+func (c *PowerCollector) updatePowerSupplies(client *redfish.Client) error {
+	// Use only the requested chassis when one was specified
+	if id := c.chassisScope(); id != "" {
+		chassis, err := c.getChassis(client)
+		if err != nil {
+			return fmt.Errorf("failed to get chassis %s: %v", id, err)
+		}
+		power, err := c.fetchPower(chassis, "PowerSupplies")
+		if err != nil {
+			return fmt.Errorf("failed to get power information from chassis %s: %w", id, err)
+		}
+		c.processPowerSupplies(chassis.ID, power, c.psuMetrics(chassis))
+		return nil
+	}
+
+	// Merge the power supplies of every chassis if requested
+	if c.opts.AllChassis {
+		return client.ForEachChassis(c.opts.ChassisWorkers, func(chassis *gofishredfish.Chassis) {
+			power, err := c.fetchPower(chassis, "PowerSupplies")
+			if err != nil {
+				c.logger.Debug("failed to get power information", "chassis", chassis.ID, "error", err)
+				return
+			}
+			c.processPowerSupplies(chassis.ID, power, c.psuMetrics(chassis))
+		})
+	}
+
 	// Try to get the primary chassis
 	chassis, err := client.GetChassisWithID("1")
 	if err != nil {
@@ -68,41 +262,13 @@ func (c *PowerCollector) Update(client *redfish.Client) error {
 	}
 
 	// Get power information
-	power, err := chassis.Power()
+	power, err := c.fetchPower(chassis, "PowerSupplies")
 	if err != nil {
 		c.logger.Debug("failed to get power information from primary chassis", "error", err)
 		return c.tryAlternativeChassis(client)
 	}
 
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	psuCount := 0
-	// Process power supplies
-	for _, psu := range power.PowerSupplies {
-		// Skip if no readings available
-		if psu.Name == "" {
-			continue
-		}
-		psuCount++
-
-		// Convert health status to float64
-		health := 2.0 // Default to Not Available
-		if psu.Status.Health != "" {
-			if psu.Status.Health == "OK" {
-				health = 1.0
-			} else {
-				health = 0.0
-			}
-		}
-
-		c.readings[psu.Name] = psuReading{
-			health:  health,
-			name:    fmt.Sprintf("PSU %d", psuCount),
-			acPower: float64(psu.PowerInputWatts),
-			dcPower: float64(psu.PowerOutputWatts),
-		}
-	}
+	c.processPowerSupplies(chassis.ID, power, c.psuMetrics(chassis))
 
 	return nil
 }
@@ -120,66 +286,244 @@ func (c *PowerCollector) tryAlternativeChassis(client *redfish.Client) error {
 		// Skip the problematic storage backplane
 		if chassis.ID == "NVMeSSD.0.Group.0.StorageBackplane" {
 			c.logger.Debug("skipping known problematic chassis", "id", chassis.ID)
+			c.skipChassis(chassis.ID, skipKnownProblematic)
 			continue
 		}
 
 		// Try to get power information from this chassis
-		power, err := chassis.Power()
+		power, err := c.fetchPower(chassis, "PowerSupplies")
 		if err != nil {
 			c.logger.Debug("failed to get power information", "chassis", chassis.ID, "error", err)
+			c.skipChassis(chassis.ID, skipNoPowerData)
 			continue
 		}
 
-		c.mutex.Lock()
-		defer c.mutex.Unlock()
-
-		psuCount := 0
-		// Process power supplies
-		for _, psu := range power.PowerSupplies {
-			// Skip if no readings available
-			if psu.Name == "" {
-				continue
-			}
-			psuCount++
-
-			// Convert health status to float64
-			health := 2.0 // Default to Not Available
-			if psu.Status.Health != "" {
-				if psu.Status.Health == "OK" {
-					health = 1.0
-				} else {
-					health = 0.0
-				}
-			}
-
-			c.readings[psu.Name] = psuReading{
-				health:  health,
-				name:    fmt.Sprintf("PSU %d", psuCount),
-				acPower: float64(psu.PowerInputWatts),
-				dcPower: float64(psu.PowerOutputWatts),
-			}
-		}
-
 		// If we found power supplies, we're done
-		if psuCount > 0 {
+		if c.processPowerSupplies(chassis.ID, power, c.psuMetrics(chassis)) > 0 {
 			c.logger.Debug("successfully retrieved power information", "chassis", chassis.ID)
 			return nil
 		}
+		c.skipChassis(chassis.ID, skipNoPowerSupplies)
 	}
 
 	// If we get here, we couldn't find any working chassis with power supplies
-	if len(c.readings) == 0 {
-		return fmt.Errorf("could not find any chassis with power supply information")
+	return fmt.Errorf("could not find any chassis with power supply information: %w", ErrUnsupported)
+}
+
+// skipChassis records that a chassis was passed over while searching for power supplies
+func (c *PowerCollector) skipChassis(id, reason string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.skipped[id] = reason
+}
+
+// psuMetrics returns the input frequency and currents of the power supplies of a chassis. The
+// legacy Power resource has no such readings, so they come from the PowerSubsystem metrics when
+// available.
+func (c *PowerCollector) psuMetrics(chassis *gofishredfish.Chassis) psuMetricsIndex {
+	var index psuMetricsIndex
+
+	subsystem, err := chassis.PowerSubsystem()
+	if err != nil || subsystem == nil {
+		return index
 	}
 
-	return nil
+	// PowerSubsystem.PowerSupplies decodes into the legacy type without metrics, so list the
+	// collection directly
+	supplies, err := gofishredfish.ListReferencedPowerSupplyUnits(subsystem.GetClient(), subsystem.ODataID+"/PowerSupplies")
+	if err != nil {
+		c.logger.Debug("failed to get power subsystem supplies", "chassis", chassis.ID, "error", err)
+		return index
+	}
+
+	for _, supply := range supplies {
+		// Read the metrics through a raw client to tell a 0 A or 0 Hz reading from an absent one
+		raw := newRawClient(supply.GetClient())
+		supply.SetClient(raw)
+		metrics, err := supply.Metrics()
+		if err != nil || metrics == nil {
+			continue
+		}
+
+		var values psuMetricsReadings
+		if err := raw.decode(metrics.ODataID, &values); err != nil {
+			c.logger.Debug("failed to decode power supply metrics", "supply", supply.Name, "error", err)
+			continue
+		}
+		index.add(supply.SerialNumber, supply.Name, psuUnitMetricsOf(values))
+	}
+	return index
+}
+
+// psuUnitMetricsOf returns the readings a power supply reports in its PowerSubsystem metrics. Rail
+// currents are labeled by their index, which RailVoltage shares. A frequency of 0 Hz is left out
+// like an absent one, since a power supply without input reads 0 A rather than 0 Hz.
+func psuUnitMetricsOf(values psuMetricsReadings) psuUnitMetrics {
+	var reading psuUnitMetrics
+	if values.FrequencyHz.Reading != nil && *values.FrequencyHz.Reading > 0 {
+		reading.frequency = *values.FrequencyHz.Reading
+		reading.frequencyPresent = true
+	}
+	if values.InputCurrentAmps.Reading != nil {
+		reading.inputCurrent = *values.InputCurrentAmps.Reading
+		reading.inputCurrentPresent = true
+	}
+	for i, rail := range values.RailCurrentAmps {
+		if rail.Reading == nil {
+			continue
+		}
+		if reading.railCurrents == nil {
+			reading.railCurrents = make(map[string]float64)
+		}
+		reading.railCurrents[strconv.Itoa(i)] = *rail.Reading
+	}
+	return reading
+}
+
+// processPowerSupplies stores the readings of all power supplies of a chassis and returns how many
+// were found
+func (c *PowerCollector) processPowerSupplies(chassisID string, power *gofishredfish.Power, metrics psuMetricsIndex) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	names := make(map[string]int)
+	for _, psu := range power.PowerSupplies {
+		names[psu.Name]++
+	}
+
+	c.subsystemHealth = worstHealth(c.subsystemHealth, powerSubsystemHealth(power))
+	c.subsystemPresent = true
+
+	psuCount := 0
+	for _, psu := range power.PowerSupplies {
+		// Prefer the member ID, which stays stable across firmware versions unlike the name
+		name := psu.MemberID
+		if name == "" {
+			name = psu.Name
+		}
+
+		// Skip if no readings available
+		if name == "" {
+			continue
+		}
+		psuCount++
+		unitMetrics := metrics.lookup(psu, names[psu.Name] == 1)
+
+		// Keep the legacy naming based on iteration order if requested
+		if c.opts.PSUSyntheticNames {
+			name = fmt.Sprintf("PSU %d", len(c.readings)+1)
+		}
+
+		c.readings[c.chassisKey(chassisID, name)] = psuReading{
+			status:         psu.Status,
+			name:           name,
+			chassis:        chassisID,
+			acPower:        float64(psu.PowerInputWatts),
+			dcPower:        float64(psu.PowerOutputWatts),
+			odataID:        psu.ODataID,
+			psuUnitMetrics: unitMetrics,
+		}
+	}
+
+	return psuCount
+}
+
+// collectPDUs stores the outlet and mains readings of the rack PDUs of the service and returns
+// how many PDUs were read
+func (c *PowerCollector) collectPDUs(client *redfish.Client) int {
+	equipment, err := client.PowerEquipment()
+	if err != nil {
+		c.logger.Debug("failed to get power equipment", "error", err)
+		return 0
+	}
+	if equipment == nil {
+		return 0
+	}
+
+	pdus, err := equipment.RackPDUs()
+	if err != nil {
+		c.logger.Debug("failed to get rack PDUs", "error", err)
+		return 0
+	}
+
+	for _, pdu := range pdus {
+		name := pdu.Name
+		if name == "" {
+			name = pdu.ID
+		}
+
+		outlets, err := pdu.Outlets()
+		if err != nil {
+			c.logger.Debug("failed to get PDU outlets", "pdu", pdu.ID, "error", err)
+		}
+		mains, err := pdu.Mains()
+		if err != nil {
+			c.logger.Debug("failed to get PDU mains", "pdu", pdu.ID, "error", err)
+		}
+
+		c.mutex.Lock()
+		for _, outlet := range outlets {
+			c.pduOutlets[outlet.ODataID] = pduReading{
+				pdu:   name,
+				name:  componentName(outlet.Name, outlet.ID),
+				power: float64(outlet.PowerWatts.Reading),
+			}
+		}
+		for _, circuit := range mains {
+			c.pduMains[circuit.ODataID] = pduReading{
+				pdu:     name,
+				name:    componentName(circuit.Name, circuit.ID),
+				power:   float64(circuit.PowerWatts.Reading),
+				voltage: float64(circuit.Voltage.Reading),
+			}
+		}
+		c.mutex.Unlock()
+	}
+
+	return len(pdus)
+}
+
+// componentName returns the name of a component, falling back to its ID
+func componentName(name, id string) string {
+	if name == "" {
+		return id
+	}
+	return name
+}
+
+// powerSubsystemHealth rolls up the power subsystem health. The Power resource has no
+// status of its own, so this uses the redundancy groups and falls back to the supplies.
+func powerSubsystemHealth(power *gofishredfish.Power) common.Health {
+	var healths []common.Health
+	for _, redundancy := range power.Redundancy {
+		healths = append(healths, redundancy.Status.Health)
+	}
+	if health := worstHealth(healths...); health != "" {
+		return health
+	}
+
+	healths = healths[:0]
+	for _, psu := range power.PowerSupplies {
+		healths = append(healths, psu.Status.Health)
+	}
+	return worstHealth(healths...)
 }
 
 // Describe describes all metrics this collector exposes
 func (c *PowerCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- c.psuHealth
+	c.DescribeHealth(ch, c.psuHealth)
 	ch <- c.psuACInputPower
 	ch <- c.psuDCPower
+	ch <- c.psuFrequency
+	ch <- c.psuInputAmps
+	ch <- c.psuOutputAmps
+	ch <- c.psuCount
+	c.DescribeHealth(ch, c.powerHealth)
+	ch <- c.pduOutletPower
+	ch <- c.pduInputPower
+	ch <- c.pduInputVoltage
+	ch <- c.skippedDesc
 	c.DescribeScrapeTime(ch)
 }
 
@@ -189,25 +533,108 @@ func (c *PowerCollector) Collect(ch chan<- prometheus.Metric) {
 	defer c.mutex.Unlock()
 
 	for _, reading := range c.readings {
-		ch <- prometheus.MustNewConstMetric(
-			c.psuHealth,
-			prometheus.GaugeValue,
-			reading.health,
-			reading.name,
-		)
+		c.CollectStatus(ch, c.psuHealth, reading.status, c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...)
 
-		ch <- prometheus.MustNewConstMetric(
+		c.Emit(
+			ch,
 			c.psuACInputPower,
 			prometheus.GaugeValue,
 			reading.acPower,
-			reading.name,
+			c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
 		)
 
-		ch <- prometheus.MustNewConstMetric(
+		c.Emit(
+			ch,
 			c.psuDCPower,
 			prometheus.GaugeValue,
 			reading.dcPower,
-			reading.name,
+			c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+		)
+
+		if reading.frequencyPresent {
+			c.Emit(
+				ch,
+				c.psuFrequency,
+				prometheus.GaugeValue,
+				reading.frequency,
+				c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+			)
+		}
+
+		if reading.inputCurrentPresent {
+			c.Emit(
+				ch,
+				c.psuInputAmps,
+				prometheus.GaugeValue,
+				reading.inputCurrent,
+				c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+			)
+		}
+
+		for rail, amps := range reading.railCurrents {
+			c.Emit(
+				ch,
+				c.psuOutputAmps,
+				prometheus.GaugeValue,
+				amps,
+				c.chassisComponentValues(reading.chassis, reading.odataID, reading.name, rail)...,
+			)
+		}
+	}
+
+	// A failed fetch is no evidence of missing power supplies
+	if c.subsystemPresent {
+		c.Emit(
+			ch,
+			c.psuCount,
+			prometheus.GaugeValue,
+			float64(len(c.readings)),
+		)
+
+		c.CollectHealth(ch, c.powerHealth, c.subsystemHealth)
+	}
+
+	for _, outlet := range c.pduOutlets {
+		c.Emit(
+			ch,
+			c.pduOutletPower,
+			prometheus.GaugeValue,
+			outlet.power,
+			outlet.pdu,
+			outlet.name,
+		)
+	}
+
+	for _, mains := range c.pduMains {
+		c.Emit(
+			ch,
+			c.pduInputPower,
+			prometheus.GaugeValue,
+			mains.power,
+			mains.pdu,
+			mains.name,
+		)
+
+		if mains.voltage > 0 {
+			c.Emit(
+				ch,
+				c.pduInputVoltage,
+				prometheus.GaugeValue,
+				mains.voltage,
+				mains.pdu,
+				mains.name,
+			)
+		}
+	}
+
+	for id, reason := range c.skipped {
+		c.Emit(
+			ch,
+			c.skippedDesc,
+			prometheus.GaugeValue,
+			1,
+			id,
+			reason,
 		)
 	}
 
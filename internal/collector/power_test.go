@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+// psuMetricsWithName returns an index of the metrics of a single power supply with the given name
+func psuMetricsWithName(name string, metrics psuUnitMetrics) psuMetricsIndex {
+	var index psuMetricsIndex
+	index.add("", name, metrics)
+	return index
+}
+
+func TestPowerSupplyLabelsSurviveRenames(t *testing.T) {
+	// The same power supply as named by two firmware versions
+	var labels []string
+	for _, name := range []string{"PS1 Status", "Power Supply 1"} {
+		c := NewPowerCollector(Options{})
+		c.processPowerSupplies("1", &gofishredfish.Power{
+			PowerSupplies: []gofishredfish.PowerSupply{{
+				Entity:          common.Entity{Name: name},
+				MemberID:        "0",
+				PowerInputWatts: 250,
+				Status:          common.Status{State: common.EnabledState, Health: common.OKHealth},
+			}},
+		}, psuMetricsWithName(name, psuUnitMetrics{frequency: 50, frequencyPresent: true}))
+
+		metrics := gather(t, c)
+		if len(metrics["ipmi_psu_ac_input_power_watts"]) != 1 || len(metrics["ipmi_psu_input_frequency_hz"]) != 1 {
+			t.Fatalf("got %v, want the power and frequency of one power supply", metrics)
+		}
+		labels = append(labels, labelValue(metrics["ipmi_psu_ac_input_power_watts"][0], "name"))
+	}
+
+	if labels[0] != "0" || labels[1] != "0" {
+		t.Errorf("got power supplies labeled %q, want both labeled by their member ID", labels)
+	}
+}
+
+func TestPowerSupplyLabelFallsBackToName(t *testing.T) {
+	c := NewPowerCollector(Options{})
+	c.processPowerSupplies("1", &gofishredfish.Power{
+		PowerSupplies: []gofishredfish.PowerSupply{{
+			Entity:          common.Entity{Name: "PSU1"},
+			PowerInputWatts: 250,
+			Status:          common.Status{State: common.EnabledState, Health: common.OKHealth},
+		}},
+	}, psuMetricsIndex{})
+
+	powers := gather(t, c)["ipmi_psu_ac_input_power_watts"]
+	if len(powers) != 1 || labelValue(powers[0], "name") != "PSU1" {
+		t.Errorf("got %v, want the power supply labeled by its name", powers)
+	}
+}
+
+func TestPowerSupplyMetricsPerRail(t *testing.T) {
+	// A power supply that lost its feed, with its 5V rail not reporting
+	var values psuMetricsReadings
+	if err := json.Unmarshal([]byte(`{
+		"@odata.id": "/redfish/v1/Chassis/1/PowerSubsystem/PowerSupplies/0/Metrics",
+		"InputCurrentAmps": {"Reading": 0},
+		"FrequencyHz": {"Reading": 0},
+		"RailVoltage": [{"Reading": 12.1}, {"Reading": 5.0}, {"Reading": 3.3}],
+		"RailCurrentAmps": [{"Reading": 0}, {}, {"Reading": 2.5}]
+	}`), &values); err != nil {
+		t.Fatalf("failed to decode metrics: %v", err)
+	}
+
+	c := NewPowerCollector(Options{})
+	c.processPowerSupplies("1", &gofishredfish.Power{
+		PowerSupplies: []gofishredfish.PowerSupply{{
+			Entity:   common.Entity{Name: "PSU1"},
+			MemberID: "0",
+			Status:   common.Status{State: common.EnabledState, Health: common.CriticalHealth},
+		}},
+	}, psuMetricsWithName("PSU1", psuUnitMetricsOf(values)))
+
+	metrics := gather(t, c)
+	if input := metrics["ipmi_psu_input_current_amps"]; len(input) != 1 || input[0].GetGauge().GetValue() != 0 {
+		t.Errorf("got input current %v, want a 0 A reading", input)
+	}
+	if _, ok := metrics["ipmi_psu_input_frequency_hz"]; ok {
+		t.Error("got an input frequency the power supply doesn't report")
+	}
+
+	rails := make(map[string]float64)
+	for _, m := range metrics["ipmi_psu_output_current_amps"] {
+		rails[labelValue(m, "rail")] = m.GetGauge().GetValue()
+	}
+	if len(rails) != 2 || rails["0"] != 0 || rails["2"] != 2.5 {
+		t.Errorf("got rail currents %v, want 0 A on rail 0 and 2.5 A on rail 2", rails)
+	}
+}
+
+func TestPowerSupplyMetricsAttribution(t *testing.T) {
+	var index psuMetricsIndex
+	index.add("SN1", "PSU", psuUnitMetrics{frequency: 50, frequencyPresent: true})
+	index.add("SN2", "PSU", psuUnitMetrics{frequency: 60, frequencyPresent: true})
+
+	c := NewPowerCollector(Options{})
+	c.processPowerSupplies("1", &gofishredfish.Power{
+		PowerSupplies: []gofishredfish.PowerSupply{
+			// Named alike, told apart by their serial numbers
+			{Entity: common.Entity{Name: "Power Supply"}, MemberID: "0", SerialNumber: "SN2"},
+			{Entity: common.Entity{Name: "Power Supply"}, MemberID: "1", SerialNumber: "SN1"},
+		},
+	}, index)
+
+	frequencies := make(map[string]float64)
+	for _, m := range gather(t, c)["ipmi_psu_input_frequency_hz"] {
+		frequencies[labelValue(m, "name")] = m.GetGauge().GetValue()
+	}
+	if len(frequencies) != 2 || frequencies["0"] != 60 || frequencies["1"] != 50 {
+		t.Errorf("got frequencies %v, want 60 Hz for SN2 and 50 Hz for SN1", frequencies)
+	}
+
+	// Without serial numbers, a name shared by several power supplies can't be attributed
+	c = NewPowerCollector(Options{})
+	c.processPowerSupplies("1", &gofishredfish.Power{
+		PowerSupplies: []gofishredfish.PowerSupply{
+			{Entity: common.Entity{Name: "PSU"}, MemberID: "0"},
+			{Entity: common.Entity{Name: "PSU"}, MemberID: "1"},
+		},
+	}, index)
+	if n := len(gather(t, c)["ipmi_psu_input_frequency_hz"]); n != 0 {
+		t.Errorf("got %d frequencies, want none for power supplies sharing a name", n)
+	}
+}
@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/stmcginnis/gofish/common"
+)
+
+// rawClient is a gofish client that keeps the JSON of the resources read through it by their
+// @odata.id. gofish decodes absent properties as zero values, so readings that may legitimately
+// be zero are looked up in the JSON to tell whether the BMC reports them.
+type rawClient struct {
+	common.Client
+
+	mutex     sync.Mutex
+	resources map[string][]byte
+}
+
+// newRawClient wraps a gofish client to keep the JSON of the resources read through it
+func newRawClient(client common.Client) *rawClient {
+	return &rawClient{Client: client, resources: make(map[string][]byte)}
+}
+
+// Get reads a resource, keeping its JSON
+func (c *rawClient) Get(url string) (*http.Response, error) {
+	resp, err := c.Client.Get(url)
+	if err != nil {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var resource struct {
+		ODataID string `json:"@odata.id"`
+	}
+	if err := json.Unmarshal(body, &resource); err != nil || resource.ODataID == "" {
+		resource.ODataID = url
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.resources[resource.ODataID] = body
+	return resp, nil
+}
+
+// has reports whether a resource read through the client has a non-null property at the given
+// path of nested objects
+func (c *rawClient) has(odataID string, path ...string) bool {
+	c.mutex.Lock()
+	raw := c.resources[odataID]
+	c.mutex.Unlock()
+
+	return rawHas(raw, path...)
+}
+
+// rawHas reports whether a JSON object has a non-null property at the given path of nested objects
+func rawHas(raw []byte, path ...string) bool {
+	for _, name := range path {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return false
+		}
+		raw = object[name]
+		if raw == nil || bytes.Equal(raw, []byte("null")) {
+			return false
+		}
+	}
+	return len(raw) > 0
+}
+
+// emptyCollection reports whether a resource read through the client lists a property as an empty
+// array, as opposed to leaving it out
+func (c *rawClient) emptyCollection(odataID, property string) bool {
+	var object map[string]json.RawMessage
+	if err := c.decode(odataID, &object); err != nil {
+		return false
+	}
+	var members []json.RawMessage
+	if err := json.Unmarshal(object[property], &members); err != nil {
+		return false
+	}
+	return members != nil && len(members) == 0
+}
+
+// decode decodes the JSON of a resource read through the client
+func (c *rawClient) decode(odataID string, v any) error {
+	c.mutex.Lock()
+	raw := c.resources[odataID]
+	c.mutex.Unlock()
+
+	return json.Unmarshal(raw, v)
+}
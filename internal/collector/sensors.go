@@ -6,6 +6,8 @@ import (
 	"github.com/mllnd/sherlock/internal/redfish"
 	"github.com/mllnd/sherlock/pkg/utils"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
 )
 
 // SensorCollector collects various sensor metrics
@@ -13,47 +15,77 @@ type SensorCollector struct {
 	BaseCollector
 	temperature       *prometheus.Desc
 	voltage           *prometheus.Desc
-	temperatureHealth *prometheus.Desc
-	voltageHealth     *prometheus.Desc
+	temperatureHealth healthMetric
+	voltageHealth     healthMetric
+	count             *prometheus.Desc
 	readings          map[string]sensorReading
+
+	// Whether a resource listing sensors was read, so that a failed fetch isn't counted as no sensors
+	listed bool
+
+	// Relative humidity, reported by some chassis and PDUs
+	humidityDesc *prometheus.Desc
+	humidity     map[string]sensorReading
+
+	// How long each sensor reading has been unchanged, if staleness detection is enabled
+	staleDesc *prometheus.Desc
+	unchanged map[string]time.Duration
 }
 
 type sensorReading struct {
 	value      float64
-	health     float64
+	status     common.Status
 	name       string
 	sensorType string
+	chassis    string
+	odataID    string
+
+	// Whether the value lies outside the plausible range of its sensor type, e.g. a sentinel
+	// reported for "no reading", in which case only the health of the sensor is exported
+	implausible bool
 }
 
 // NewSensorCollector creates a new SensorCollector
-func NewSensorCollector() *SensorCollector {
+func NewSensorCollector(opts Options) *SensorCollector {
 	return &SensorCollector{
-		BaseCollector: NewBaseCollector("ipmi", "sensor"),
-		temperature: prometheus.NewDesc(
+		BaseCollector: NewBaseCollector("ipmi", "sensor", opts),
+		temperature: opts.newDesc(
 			"ipmi_temperature_celsius",
 			"Temperature reading in degree Celsius",
-			[]string{"name"},
-			nil,
+			opts.chassisComponentLabels("name"),
 		),
-		voltage: prometheus.NewDesc(
+		voltage: opts.newDesc(
 			"ipmi_voltage_volts",
 			"Voltage reading in Volts",
-			[]string{"name"},
-			nil,
+			opts.chassisComponentLabels("name"),
 		),
-		temperatureHealth: prometheus.NewDesc(
+		temperatureHealth: opts.newHealthMetric(
 			"ipmi_temperature_health",
-			"Temperature sensor health status (1 = OK, 0 = Warning/Critical, 2 = Not Available)",
-			[]string{"name"},
-			nil,
+			"Temperature sensor health status",
+			opts.chassisComponentLabels("name"),
 		),
-		voltageHealth: prometheus.NewDesc(
+		voltageHealth: opts.newHealthMetric(
 			"ipmi_voltage_health",
-			"Voltage sensor health status (1 = OK, 0 = Warning/Critical, 2 = Not Available)",
-			[]string{"name"},
+			"Voltage sensor health status",
+			opts.chassisComponentLabels("name"),
+		),
+		count: opts.newDesc(
+			"ipmi_sensor_count",
+			"Number of temperature and voltage sensors reported by the BMC",
 			nil,
 		),
+		humidityDesc: opts.newDesc(
+			"ipmi_chassis_humidity_percent",
+			"Relative humidity in percent",
+			opts.chassisComponentLabels("name"),
+		),
+		staleDesc: opts.newDesc(
+			"ipmi_sensor_stale",
+			"Whether the reading of a present temperature or voltage sensor has not changed for STALE_SCRAPES scrape intervals (1 = stale, 0 = changing)",
+			opts.chassisComponentLabels("name"),
+		),
 		readings: make(map[string]sensorReading),
+		humidity: make(map[string]sensorReading),
 	}
 }
 
@@ -65,92 +97,282 @@ func (c *SensorCollector) Update(client *redfish.Client) error {
 	// Clear previous readings
 	c.mutex.Lock()
 	c.readings = make(map[string]sensorReading)
+	c.humidity = make(map[string]sensorReading)
+	c.unchanged = nil
+	c.listed = false
 	c.mutex.Unlock()
+	defer c.trackStaleness()
+
+	// OpenBMC reports its sensors in the chassis Sensors collections rather than in Thermal/Power
+	if openBMC, err := client.IsOpenBMC(); err != nil {
+		c.logger.Debug("failed to detect OpenBMC", "error", err)
+	} else if openBMC {
+		return c.updateOpenBMC(client)
+	}
+
+	// Merge the sensors of every chassis if requested and no chassis was specified
+	if c.opts.AllChassis && c.chassisScope() == "" {
+		return client.ForEachChassis(c.opts.ChassisWorkers, func(chassis *gofishredfish.Chassis) {
+			c.processEnvironment(chassis)
+
+			if thermal, err := c.fetchThermal(chassis, "Temperatures"); err != nil {
+				c.logger.Debug("failed to get thermal information", "chassis", chassis.ID, "error", err)
+			} else {
+				c.processTemperatures(chassis.ID, thermal)
+			}
+
+			if power, err := c.fetchPower(chassis, "Voltages"); err != nil {
+				c.logger.Debug("failed to get power information", "chassis", chassis.ID, "error", err)
+			} else {
+				c.processVoltages(chassis.ID, power)
+			}
+		})
+	}
 
-	// Get Chassis ID 1
-	chassis, err := client.GetChassisWithID("1")
+	// Get the requested chassis (ID 1 by default)
+	chassis, err := c.getChassis(client)
 	if err != nil {
-		c.logger.Debug("failed to get main chassis", "error", err)
+		c.logger.Debug("failed to get chassis", "error", err)
 		return nil
 	}
 
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	c.processEnvironment(chassis)
 
 	// Get and process temperature sensors
-	thermal, err := chassis.Thermal()
+	thermal, err := c.fetchThermal(chassis, "Temperatures")
 	if err != nil {
 		c.logger.Debug("failed to get thermal information", "error", err)
+		return c.unsupported(err)
+	}
+	c.processTemperatures(chassis.ID, thermal)
+
+	// Get and process voltage sensors
+	power, err := c.fetchPower(chassis, "Voltages")
+	if err != nil {
+		// If we can't get power info, we still have the temperature readings
+		c.logger.Debug("failed to get power information", "error", err)
 		return nil
 	}
+	c.processVoltages(chassis.ID, power)
 
-	// Process temperature sensors
-	for _, temp := range thermal.Temperatures {
-		if temp.Name == "" {
+	return nil
+}
+
+// updateOpenBMC reads the sensors of the requested chassis, or of every chassis since OpenBMC
+// names its chassis after the board instead of numbering them
+func (c *SensorCollector) updateOpenBMC(client *redfish.Client) error {
+	if c.chassisScope() != "" {
+		chassis, err := c.getChassis(client)
+		if err != nil {
+			c.logger.Debug("failed to get chassis", "error", err)
+			return nil
+		}
+		c.processSensors(chassis)
+		return nil
+	}
+
+	return client.ForEachChassis(c.opts.ChassisWorkers, c.processSensors)
+}
+
+// processSensors stores the temperature and voltage readings of the Sensors collection of a chassis
+func (c *SensorCollector) processSensors(chassis *gofishredfish.Chassis) {
+	sensors, err := chassis.Sensors()
+	if err != nil {
+		c.logger.Debug("failed to get sensors", "chassis", chassis.ID, "error", err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.listed = true
+	for _, sensor := range sensors {
+		if sensor.Name == "" || !c.opts.keepSensor(sensor.Name) {
 			continue
 		}
 
-		health := 2.0 // Default to Not Available
-		if temp.Status.Health != "" {
-			if temp.Status.Health == "OK" {
-				health = 1.0
-			} else {
-				health = 0.0
-			}
+		reading := sensorReading{
+			status:  sensor.Status,
+			name:    sensor.Name,
+			chassis: chassis.ID,
+			odataID: sensor.ODataID,
+		}
+		var base string
+		switch sensor.ReadingType {
+		case gofishredfish.TemperatureReadingType:
+			reading.sensorType = "temperature"
+			base = unitCelsius
+		case gofishredfish.VoltageReadingType:
+			reading.sensorType = "voltage"
+			base = unitVolts
+		case gofishredfish.HumidityReadingType:
+			reading.sensorType = "humidity"
+			base = unitPercent
+		default:
+			continue
 		}
 
-		c.readings[temp.Name] = sensorReading{
-			value:      float64(temp.ReadingCelsius),
-			health:     health,
-			name:       temp.Name,
-			sensorType: "temperature",
+		value, ok := normalizeReading(float64(sensor.Reading), sensor.ReadingUnits, base)
+		if !ok {
+			c.logger.Debug("skipping sensor with unsupported units", "sensor", sensor.Name, "units", sensor.ReadingUnits)
+			continue
+		}
+		reading.value = value
+		if base == unitVolts {
+			reading.value = utils.Round(value, 3)
 		}
+
+		if reading.sensorType == "humidity" {
+			c.humidity[c.chassisKey(chassis.ID, sensor.Name)] = reading
+			continue
+		}
+		c.checkRange(&reading)
+		c.readings[c.chassisKey(chassis.ID, sensor.Name)] = reading
 	}
+}
 
-	// Get and process voltage sensors
-	power, err := chassis.Power()
+// processEnvironment stores the humidity reading of the environment metrics of a chassis, if any
+func (c *SensorCollector) processEnvironment(chassis *gofishredfish.Chassis) {
+	// Read the environment metrics through a raw client to tell whether the humidity is reported
+	client := chassis.GetClient()
+	raw := newRawClient(client)
+	chassis.SetClient(raw)
+	metrics, err := chassis.EnvironmentMetrics()
+	chassis.SetClient(client)
 	if err != nil {
-		// If we can't get power info, but we have temperature readings,
-		// return success as we at least have some data
-		if len(c.readings) > 0 {
-			return nil
-		}
-		c.logger.Debug("failed to get power information", "error", err)
-		return nil
+		c.logger.Debug("failed to get environment metrics", "chassis", chassis.ID, "error", err)
+		return
+	}
+	if metrics == nil || !raw.has(metrics.ODataID, "HumidityPercent", "Reading") {
+		return
 	}
 
-	// Process all voltage sensors
-	for _, volt := range power.Voltages {
-		if volt.Name == "" {
+	name := chassis.Name
+	if name == "" {
+		name = chassis.ID
+	}
+	if !c.opts.keepSensor(name) {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.humidity[c.chassisKey(chassis.ID, name)] = sensorReading{
+		value:      float64(metrics.HumidityPercent.Reading),
+		name:       name,
+		sensorType: "humidity",
+		chassis:    chassis.ID,
+		odataID:    metrics.HumidityPercent.DataSourceURI,
+	}
+}
+
+// processTemperatures stores the temperature sensor readings of the thermal resource of a chassis
+func (c *SensorCollector) processTemperatures(chassisID string, thermal *gofishredfish.Thermal) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.listed = true
+	for _, temp := range thermal.Temperatures {
+		if temp.Name == "" || !c.opts.keepSensor(temp.Name) {
 			continue
 		}
 
-		health := 2.0 // Default to Not Available
-		if volt.Status.Health != "" {
-			if volt.Status.Health == "OK" {
-				health = 1.0
-			} else {
-				health = 0.0
-			}
+		reading := sensorReading{
+			value:      float64(temp.ReadingCelsius),
+			status:     temp.Status,
+			name:       temp.Name,
+			sensorType: "temperature",
+			chassis:    chassisID,
+			odataID:    temp.ODataID,
+		}
+		c.checkRange(&reading)
+		c.readings[c.chassisKey(chassisID, temp.Name)] = reading
+	}
+}
+
+// processVoltages stores the voltage sensor readings of the power resource of a chassis
+func (c *SensorCollector) processVoltages(chassisID string, power *gofishredfish.Power) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.listed = true
+	for _, volt := range power.Voltages {
+		if volt.Name == "" || !c.opts.keepSensor(volt.Name) {
+			continue
 		}
 
-		c.readings[volt.Name] = sensorReading{
+		reading := sensorReading{
 			value:      utils.Round(float64(volt.ReadingVolts), 3),
-			health:     health,
+			status:     volt.Status,
 			name:       volt.Name,
 			sensorType: "voltage",
+			chassis:    chassisID,
+			odataID:    volt.ODataID,
 		}
+		c.checkRange(&reading)
+		c.readings[c.chassisKey(chassisID, volt.Name)] = reading
 	}
+}
 
-	return nil
+// checkRange marks a temperature or voltage reading outside of the configured plausible range
+func (c *SensorCollector) checkRange(reading *sensorReading) {
+	plausible := true
+	switch reading.sensorType {
+	case "temperature":
+		plausible = c.opts.TemperatureRange.plausible(reading.value)
+	case "voltage":
+		plausible = c.opts.VoltageRange.plausible(reading.value)
+	}
+
+	if !plausible {
+		c.logger.Debug("skipping implausible sensor reading", "sensor", reading.name, "type", reading.sensorType, "value", reading.value)
+		reading.implausible = true
+	}
+}
+
+// trackStaleness records the present sensor readings of this scrape for staleness detection
+func (c *SensorCollector) trackStaleness() {
+	if c.opts.StaleScrapes <= 0 {
+		return
+	}
+
+	scope := c.chassisScope()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	values := make(map[string]float64, len(c.readings))
+	for key, reading := range c.readings {
+		if reading.status.State != common.AbsentState && !reading.implausible {
+			values[key] = reading.value
+		}
+	}
+
+	// Keep the previous readings through a scrape that read none, e.g. because the BMC was unreachable
+	if len(values) == 0 {
+		return
+	}
+	c.unchanged = staleness.update(c.target+"/"+scope, values, c.staleAfter())
+}
+
+// staleAfter returns how long a reading has to be unchanged to be stale: STALE_SCRAPES scrape
+// intervals
+func (c *SensorCollector) staleAfter() time.Duration {
+	return time.Duration(c.opts.StaleScrapes) * c.opts.ScrapeInterval
 }
 
 // Describe describes all metrics this collector exposes
 func (c *SensorCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.temperature
 	ch <- c.voltage
-	ch <- c.temperatureHealth
-	ch <- c.voltageHealth
+	ch <- c.count
+	ch <- c.humidityDesc
+	if c.opts.StaleScrapes > 0 {
+		ch <- c.staleDesc
+	}
+	c.DescribeHealth(ch, c.temperatureHealth)
+	c.DescribeHealth(ch, c.voltageHealth)
 	c.DescribeScrapeTime(ch)
 }
 
@@ -162,33 +384,63 @@ func (c *SensorCollector) Collect(ch chan<- prometheus.Metric) {
 	for _, reading := range c.readings {
 		switch reading.sensorType {
 		case "temperature":
-			ch <- prometheus.MustNewConstMetric(
-				c.temperature,
-				prometheus.GaugeValue,
-				reading.value,
-				reading.name,
-			)
-			ch <- prometheus.MustNewConstMetric(
-				c.temperatureHealth,
-				prometheus.GaugeValue,
-				reading.health,
-				reading.name,
-			)
+			if !reading.implausible {
+				c.Emit(
+					ch,
+					c.temperature,
+					prometheus.GaugeValue,
+					reading.value,
+					c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+				)
+			}
+			c.CollectStatus(ch, c.temperatureHealth, reading.status, c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...)
 		case "voltage":
-			ch <- prometheus.MustNewConstMetric(
-				c.voltage,
-				prometheus.GaugeValue,
-				reading.value,
-				reading.name,
-			)
-			ch <- prometheus.MustNewConstMetric(
-				c.voltageHealth,
-				prometheus.GaugeValue,
-				reading.health,
-				reading.name,
-			)
+			if !reading.implausible {
+				c.Emit(
+					ch,
+					c.voltage,
+					prometheus.GaugeValue,
+					reading.value,
+					c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+				)
+			}
+			c.CollectStatus(ch, c.voltageHealth, reading.status, c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...)
 		}
 	}
 
+	for key, unchanged := range c.unchanged {
+		reading := c.readings[key]
+		stale := 0.0
+		if unchanged >= c.staleAfter() {
+			stale = 1.0
+		}
+		c.Emit(
+			ch,
+			c.staleDesc,
+			prometheus.GaugeValue,
+			stale,
+			c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+		)
+	}
+
+	for _, reading := range c.humidity {
+		c.Emit(
+			ch,
+			c.humidityDesc,
+			prometheus.GaugeValue,
+			reading.value,
+			c.chassisComponentValues(reading.chassis, reading.odataID, reading.name)...,
+		)
+	}
+
+	if c.listed {
+		c.Emit(
+			ch,
+			c.count,
+			prometheus.GaugeValue,
+			float64(len(c.readings)),
+		)
+	}
+
 	c.CollectScrapeTime(ch)
 }
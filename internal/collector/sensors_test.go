@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+func TestSensorsOfAllChassisStayApart(t *testing.T) {
+	c := NewSensorCollector(Options{AllChassis: true})
+	for i, chassis := range []string{"Blade1", "Blade2"} {
+		c.processTemperatures(chassis, &gofishredfish.Thermal{
+			Temperatures: []gofishredfish.Temperature{{
+				Entity:         common.Entity{Name: "Inlet Temp"},
+				ReadingCelsius: float32(20 + i),
+			}},
+		})
+	}
+
+	temperatures := gather(t, c)["ipmi_temperature_celsius"]
+	if len(temperatures) != 2 {
+		t.Fatalf("got %d temperatures, want one per chassis", len(temperatures))
+	}
+	for _, m := range temperatures {
+		want := map[string]float64{"Blade1": 20, "Blade2": 21}[labelValue(m, "chassis")]
+		if got := m.GetGauge().GetValue(); got != want {
+			t.Errorf("chassis %q reads %v, want %v", labelValue(m, "chassis"), got, want)
+		}
+	}
+}
+
+func TestSensorsWithoutAllChassisHaveNoChassisLabel(t *testing.T) {
+	c := NewSensorCollector(Options{})
+	c.processTemperatures("1", &gofishredfish.Thermal{
+		Temperatures: []gofishredfish.Temperature{{Entity: common.Entity{Name: "Inlet Temp"}, ReadingCelsius: 20}},
+	})
+
+	for _, m := range gather(t, c)["ipmi_temperature_celsius"] {
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "chassis" {
+				t.Errorf("temperature is labeled with chassis %q", label.GetValue())
+			}
+		}
+	}
+}
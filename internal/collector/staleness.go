@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// stalenessTracker remembers the last value of each sensor across scrapes and since when it
+// hasn't changed. Collectors are created for every scrape, so the state is shared by all of them,
+// keyed by target and chassis scope. Staleness is measured in time rather than in scrapes, so that
+// a target scraped by several Prometheus servers or through /metrics/all as well doesn't turn
+// stale sooner.
+type stalenessTracker struct {
+	mutex  sync.Mutex
+	scopes map[string]*staleScope
+	pruned time.Time
+}
+
+// staleScope are the sensor readings of a target and chassis scope and when they were last updated
+type staleScope struct {
+	sensors map[string]staleReading
+	updated time.Time
+}
+
+// staleReading is the last value of a sensor and since when it has been repeated
+type staleReading struct {
+	value float64
+	since time.Time
+}
+
+// staleness tracks the sensor readings of every target
+var staleness = &stalenessTracker{scopes: make(map[string]*staleScope)}
+
+// update records the current readings of a scope by sensor name and returns for how long each one
+// has been unchanged. Sensors missing from the readings are forgotten, and so are scopes that
+// haven't been updated for twice maxAge, e.g. because their target was removed.
+func (t *stalenessTracker) update(scope string, values map[string]float64, maxAge time.Duration) map[string]time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(t.pruned) >= maxAge {
+		for key, idle := range t.scopes {
+			if now.Sub(idle.updated) >= 2*maxAge {
+				delete(t.scopes, key)
+			}
+		}
+		t.pruned = now
+	}
+
+	var previous map[string]staleReading
+	if last, ok := t.scopes[scope]; ok {
+		previous = last.sensors
+	}
+	current := make(map[string]staleReading, len(values))
+	unchanged := make(map[string]time.Duration, len(values))
+	for name, value := range values {
+		reading := staleReading{value: value, since: now}
+		if last, ok := previous[name]; ok && last.value == value {
+			reading.since = last.since
+		}
+		current[name] = reading
+		unchanged[name] = now.Sub(reading.since)
+	}
+
+	t.scopes[scope] = &staleScope{sensors: current, updated: now}
+	return unchanged
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStalenessIgnoresRepeatedScrapes(t *testing.T) {
+	tracker := &stalenessTracker{scopes: make(map[string]*staleScope)}
+	values := map[string]float64{"CPU1 Temp": 42}
+
+	// Two Prometheus servers scraping the same target right after each other
+	tracker.update("bmc/", values, time.Minute)
+	unchanged := tracker.update("bmc/", values, time.Minute)
+	if unchanged["CPU1 Temp"] >= time.Minute {
+		t.Errorf("a repeated scrape made the reading unchanged for %v", unchanged["CPU1 Temp"])
+	}
+
+	// The same reading a while later
+	tracker.scopes["bmc/"].sensors["CPU1 Temp"] = staleReading{value: 42, since: time.Now().Add(-2 * time.Minute)}
+	if unchanged := tracker.update("bmc/", values, time.Minute); unchanged["CPU1 Temp"] < 2*time.Minute {
+		t.Errorf("got the reading unchanged for %v, want at least 2m", unchanged["CPU1 Temp"])
+	}
+
+	// A changed reading starts over
+	if unchanged := tracker.update("bmc/", map[string]float64{"CPU1 Temp": 43}, time.Minute); unchanged["CPU1 Temp"] >= time.Minute {
+		t.Errorf("a changed reading has been unchanged for %v", unchanged["CPU1 Temp"])
+	}
+}
+
+func TestStalenessForgetsIdleScopes(t *testing.T) {
+	tracker := &stalenessTracker{scopes: make(map[string]*staleScope)}
+	tracker.update("removed/", map[string]float64{"CPU1 Temp": 42}, time.Minute)
+	tracker.scopes["removed/"].updated = time.Now().Add(-3 * time.Minute)
+	tracker.pruned = time.Time{}
+
+	tracker.update("bmc/", map[string]float64{"CPU1 Temp": 42}, time.Minute)
+	if _, ok := tracker.scopes["removed/"]; ok {
+		t.Error("the readings of a target that is no longer scraped were kept")
+	}
+	if _, ok := tracker.scopes["bmc/"]; !ok {
+		t.Error("the readings of the scraped target were forgotten")
+	}
+}
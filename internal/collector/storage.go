@@ -0,0 +1,296 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/mllnd/sherlock/internal/redfish"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+// StorageCollector collects storage controller, volume and drive metrics
+type StorageCollector struct {
+	BaseCollector
+	volumeHealth   healthMetric
+	volumeCapacity *prometheus.Desc
+	volumeUsed     *prometheus.Desc
+	volumes        map[string]volumeReading
+
+	// Media wear and temperature of the drives
+	driveLifeUsed    *prometheus.Desc
+	driveTemperature *prometheus.Desc
+	drives           map[string]driveReading
+}
+
+type volumeReading struct {
+	status      common.Status
+	raidType    string
+	capacity    float64
+	used        float64
+	usedPresent bool
+	name        string
+	odataID     string
+}
+
+type driveReading struct {
+	lifeUsed           float64
+	lifeUsedPresent    bool
+	temperature        float64
+	temperaturePresent bool
+	name               string
+	odataID            string
+}
+
+// NewStorageCollector creates a new StorageCollector
+func NewStorageCollector(opts Options) *StorageCollector {
+	return &StorageCollector{
+		BaseCollector: NewBaseCollector("ipmi", "storage", opts),
+		volumeHealth: opts.newHealthMetric(
+			"ipmi_volume_health",
+			"Logical volume health status",
+			opts.componentLabels("name", "raid_type"),
+		),
+		volumeCapacity: opts.newDesc(
+			"ipmi_volume_capacity_bytes",
+			"Logical volume capacity in bytes",
+			opts.componentLabels("name"),
+		),
+		volumeUsed: opts.newDesc(
+			"ipmi_volume_used_bytes",
+			"Logical volume capacity in use in bytes, derived from the remaining capacity percentage",
+			opts.componentLabels("name"),
+		),
+		driveLifeUsed: opts.newDesc(
+			"ipmi_drive_media_life_used_percent",
+			"Percentage of the rated media life of the drive that is used up",
+			opts.componentLabels("name"),
+		),
+		driveTemperature: opts.newDesc(
+			"ipmi_drive_temperature_celsius",
+			"Drive temperature in degree Celsius",
+			opts.componentLabels("name"),
+		),
+		volumes: make(map[string]volumeReading),
+		drives:  make(map[string]driveReading),
+	}
+}
+
+// Update fetches new metrics and updates the prometheus metrics
+func (c *StorageCollector) Update(client *redfish.Client) error {
+	start := time.Now()
+	defer c.RecordScrapeTime(start)
+
+	// Clear previous readings
+	c.mutex.Lock()
+	c.volumes = make(map[string]volumeReading)
+	c.drives = make(map[string]driveReading)
+	c.mutex.Unlock()
+
+	// Get all systems
+	systems, err := client.GetSystems()
+	if err != nil || len(systems) == 0 {
+		c.logger.Debug("failed to get systems", "error", err)
+		return nil
+	}
+
+	storages, err := systems[0].Storage()
+	if err != nil {
+		c.logger.Debug("failed to get storage", "error", err)
+		return c.unsupported(err)
+	}
+
+	for _, storage := range storages {
+		c.processStorage(storage)
+	}
+
+	return nil
+}
+
+// processStorage stores the readings of the volumes and drives of a storage subsystem. They are
+// read through a raw client to tell whether the BMC reports readings that may legitimately be 0.
+func (c *StorageCollector) processStorage(storage *gofishredfish.Storage) {
+	raw := newRawClient(storage.GetClient())
+	storage.SetClient(raw)
+	c.processVolumes(storage, raw)
+	c.processDrives(storage, raw)
+}
+
+// processVolumes stores the readings of the volumes of a storage subsystem, read through raw
+func (c *StorageCollector) processVolumes(storage *gofishredfish.Storage, raw *rawClient) {
+	volumes, err := storage.Volumes()
+	if err != nil {
+		c.logger.Debug("failed to get volumes", "storage", storage.ID, "error", err)
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, volume := range volumes {
+		name := volume.Name
+		if name == "" {
+			name = volume.ID
+		}
+
+		reading := volumeReading{
+			status:   volume.Status,
+			raidType: string(volume.RAIDType),
+			capacity: float64(volume.CapacityBytes),
+			name:     name,
+			odataID:  volume.ODataID,
+		}
+		if raw.has(volume.ODataID, "RemainingCapacityPercent") {
+			reading.used = reading.capacity * float64(100-volume.RemainingCapacityPercent) / 100
+			reading.usedPresent = true
+		}
+
+		c.volumes[volume.ODataID] = reading
+	}
+}
+
+// processDrives stores the media wear and temperature of the drives of a storage subsystem.
+// BMCs often only report these for NVMe drives in the SMART log of the controller, which is
+// used for readings the drive itself lacks. The storage subsystem is read through raw.
+func (c *StorageCollector) processDrives(storage *gofishredfish.Storage, raw *rawClient) {
+	drives, err := storage.Drives()
+	if err != nil {
+		c.logger.Debug("failed to get drives", "storage", storage.ID, "error", err)
+		return
+	}
+
+	readings := make([]driveReading, 0, len(drives))
+	complete := true
+	for _, drive := range drives {
+		name := drive.Name
+		if name == "" {
+			name = drive.ID
+		}
+
+		reading := driveReading{
+			name:    name,
+			odataID: drive.ODataID,
+		}
+		if raw.has(drive.ODataID, "PredictedMediaLifeLeftPercent") {
+			reading.lifeUsed = 100 - float64(drive.PredictedMediaLifeLeftPercent)
+			reading.lifeUsedPresent = true
+		}
+		if metrics, err := drive.EnvironmentMetrics(); err != nil {
+			c.logger.Debug("failed to get drive environment metrics", "drive", name, "error", err)
+		} else if metrics != nil && raw.has(metrics.ODataID, "TemperatureCelsius", "Reading") {
+			reading.temperature = float64(metrics.TemperatureCelsius.Reading)
+			reading.temperaturePresent = true
+		}
+
+		complete = complete && reading.lifeUsedPresent && reading.temperaturePresent
+		readings = append(readings, reading)
+	}
+
+	// The SMART log covers the whole NVMe subsystem, so it is only attributed to a single drive
+	if !complete && len(readings) == 1 {
+		if smart := c.controllerSMART(storage); smart != nil {
+			if !readings[0].lifeUsedPresent {
+				readings[0].lifeUsed = smart.PercentageUsed
+				readings[0].lifeUsedPresent = true
+			}
+			if !readings[0].temperaturePresent {
+				readings[0].temperature = smart.CompositeTemperatureCelsius
+				readings[0].temperaturePresent = true
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, reading := range readings {
+		c.drives[reading.odataID] = reading
+	}
+}
+
+// controllerSMART returns the NVMe SMART metrics of the only controller of a storage subsystem,
+// or nil if it has several controllers or doesn't report them
+func (c *StorageCollector) controllerSMART(storage *gofishredfish.Storage) *gofishredfish.NVMeSMARTMetrics {
+	controllers, err := storage.Controllers()
+	if err != nil {
+		c.logger.Debug("failed to get storage controllers", "storage", storage.ID, "error", err)
+		return nil
+	}
+	if len(controllers) != 1 {
+		return nil
+	}
+
+	metrics, err := controllers[0].Metrics()
+	if err != nil {
+		c.logger.Debug("failed to get storage controller metrics", "controller", controllers[0].ID, "error", err)
+		return nil
+	}
+
+	// A running NVMe controller always reports its composite temperature
+	if metrics == nil || metrics.NVMeSMART.CompositeTemperatureCelsius <= 0 {
+		return nil
+	}
+	return &metrics.NVMeSMART
+}
+
+// Describe describes all metrics this collector exposes
+func (c *StorageCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.DescribeHealth(ch, c.volumeHealth)
+	ch <- c.volumeCapacity
+	ch <- c.volumeUsed
+	ch <- c.driveLifeUsed
+	ch <- c.driveTemperature
+	c.DescribeScrapeTime(ch)
+}
+
+// Collect collects all metrics
+func (c *StorageCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, volume := range c.volumes {
+		c.CollectStatus(ch, c.volumeHealth, volume.status, c.componentValues(volume.odataID, volume.name, volume.raidType)...)
+
+		c.Emit(
+			ch,
+			c.volumeCapacity,
+			prometheus.GaugeValue,
+			volume.capacity,
+			c.componentValues(volume.odataID, volume.name)...,
+		)
+
+		if volume.usedPresent {
+			c.Emit(
+				ch,
+				c.volumeUsed,
+				prometheus.GaugeValue,
+				volume.used,
+				c.componentValues(volume.odataID, volume.name)...,
+			)
+		}
+	}
+
+	for _, drive := range c.drives {
+		if drive.lifeUsedPresent {
+			c.Emit(
+				ch,
+				c.driveLifeUsed,
+				prometheus.GaugeValue,
+				drive.lifeUsed,
+				c.componentValues(drive.odataID, drive.name)...,
+			)
+		}
+
+		if drive.temperaturePresent {
+			c.Emit(
+				ch,
+				c.driveTemperature,
+				prometheus.GaugeValue,
+				drive.temperature,
+				c.componentValues(drive.odataID, drive.name)...,
+			)
+		}
+	}
+
+	c.CollectScrapeTime(ch)
+}
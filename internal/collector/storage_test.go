@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+// storageResources is a storage subsystem with a full volume and a worn-out drive whose
+// temperature is reported without a data source
+var storageResources = map[string]string{
+	"/redfish/v1/Systems/1/Storage/1": `{
+		"@odata.id": "/redfish/v1/Systems/1/Storage/1",
+		"Id": "1",
+		"Drives": [{"@odata.id": "/redfish/v1/Systems/1/Storage/1/Drives/0"}],
+		"Volumes": {"@odata.id": "/redfish/v1/Systems/1/Storage/1/Volumes"}
+	}`,
+	"/redfish/v1/Systems/1/Storage/1/Volumes": `{
+		"Members": [{"@odata.id": "/redfish/v1/Systems/1/Storage/1/Volumes/0"}]
+	}`,
+	"/redfish/v1/Systems/1/Storage/1/Volumes/0": `{
+		"@odata.id": "/redfish/v1/Systems/1/Storage/1/Volumes/0",
+		"Id": "0",
+		"Name": "VD0",
+		"CapacityBytes": 1000,
+		"RemainingCapacityPercent": 0
+	}`,
+	"/redfish/v1/Systems/1/Storage/1/Drives/0": `{
+		"@odata.id": "/redfish/v1/Systems/1/Storage/1/Drives/0",
+		"Id": "0",
+		"Name": "Disk 0",
+		"PredictedMediaLifeLeftPercent": 0,
+		"EnvironmentMetrics": {"@odata.id": "/redfish/v1/Systems/1/Storage/1/Drives/0/EnvironmentMetrics"}
+	}`,
+	"/redfish/v1/Systems/1/Storage/1/Drives/0/EnvironmentMetrics": `{
+		"@odata.id": "/redfish/v1/Systems/1/Storage/1/Drives/0/EnvironmentMetrics",
+		"TemperatureCelsius": {"Reading": 38}
+	}`,
+}
+
+func TestStorageZeroReadings(t *testing.T) {
+	storage, err := gofishredfish.GetStorage(fakeClient{resources: storageResources}, "/redfish/v1/Systems/1/Storage/1")
+	if err != nil {
+		t.Fatalf("failed to get storage: %v", err)
+	}
+
+	c := NewStorageCollector(Options{})
+	c.processStorage(storage)
+	metrics := gather(t, c)
+
+	for name, want := range map[string]float64{
+		"ipmi_volume_used_bytes":             1000,
+		"ipmi_drive_media_life_used_percent": 100,
+		"ipmi_drive_temperature_celsius":     38,
+	} {
+		series := metrics[name]
+		if len(series) != 1 {
+			t.Errorf("got %d series of %s, want 1", len(series), name)
+			continue
+		}
+		if got := series[0].GetGauge().GetValue(); got != want {
+			t.Errorf("got %s %v, want %v", name, got, want)
+		}
+	}
+}
+
+// nvmeResources returns an NVMe storage subsystem with the given drives, none of which reports
+// its wear or temperature, and a single controller reporting them in its SMART log
+func nvmeResources(drives ...string) map[string]string {
+	resources := map[string]string{
+		"/redfish/v1/Systems/1/Storage/NVMe/Controllers": `{
+			"Members": [{"@odata.id": "/redfish/v1/Systems/1/Storage/NVMe/Controllers/0"}]
+		}`,
+		"/redfish/v1/Systems/1/Storage/NVMe/Controllers/0": `{
+			"@odata.id": "/redfish/v1/Systems/1/Storage/NVMe/Controllers/0",
+			"Id": "0",
+			"Metrics": {"@odata.id": "/redfish/v1/Systems/1/Storage/NVMe/Controllers/0/Metrics"}
+		}`,
+		"/redfish/v1/Systems/1/Storage/NVMe/Controllers/0/Metrics": `{
+			"@odata.id": "/redfish/v1/Systems/1/Storage/NVMe/Controllers/0/Metrics",
+			"NVMeSMART": {"PercentageUsed": 7, "CompositeTemperatureCelsius": 41}
+		}`,
+	}
+
+	var links []string
+	for _, drive := range drives {
+		odataID := "/redfish/v1/Systems/1/Storage/NVMe/Drives/" + drive
+		links = append(links, `{"@odata.id": "`+odataID+`"}`)
+		resources[odataID] = `{"@odata.id": "` + odataID + `", "Id": "` + drive + `", "Name": "` + drive + `"}`
+	}
+	resources["/redfish/v1/Systems/1/Storage/NVMe"] = `{
+		"@odata.id": "/redfish/v1/Systems/1/Storage/NVMe",
+		"Id": "NVMe",
+		"Drives": [` + strings.Join(links, ",") + `],
+		"Controllers": {"@odata.id": "/redfish/v1/Systems/1/Storage/NVMe/Controllers"}
+	}`
+	return resources
+}
+
+func TestStorageControllerSMARTFallback(t *testing.T) {
+	storage, err := gofishredfish.GetStorage(fakeClient{resources: nvmeResources("nvme0")}, "/redfish/v1/Systems/1/Storage/NVMe")
+	if err != nil {
+		t.Fatalf("failed to get storage: %v", err)
+	}
+
+	c := NewStorageCollector(Options{})
+	c.processStorage(storage)
+	metrics := gather(t, c)
+
+	for name, want := range map[string]float64{
+		"ipmi_drive_media_life_used_percent": 7,
+		"ipmi_drive_temperature_celsius":     41,
+	} {
+		series := metrics[name]
+		if len(series) != 1 {
+			t.Errorf("got %d series of %s, want 1 from the controller SMART log", len(series), name)
+			continue
+		}
+		if got := series[0].GetGauge().GetValue(); got != want {
+			t.Errorf("got %s %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestStorageControllerSMARTNotAttributedToSeveralDrives(t *testing.T) {
+	storage, err := gofishredfish.GetStorage(fakeClient{resources: nvmeResources("nvme0", "nvme1")}, "/redfish/v1/Systems/1/Storage/NVMe")
+	if err != nil {
+		t.Fatalf("failed to get storage: %v", err)
+	}
+
+	c := NewStorageCollector(Options{})
+	c.processStorage(storage)
+	metrics := gather(t, c)
+
+	for _, name := range []string{"ipmi_drive_media_life_used_percent", "ipmi_drive_temperature_celsius"} {
+		if n := len(metrics[name]); n != 0 {
+			t.Errorf("got %d series of %s, want none for a subsystem shared by two drives", n, name)
+		}
+	}
+}
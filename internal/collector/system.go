@@ -6,47 +6,103 @@ import (
 
 	"github.com/mllnd/sherlock/internal/redfish"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
 )
 
 // SystemCollector collects system-level metrics
 type SystemCollector struct {
 	BaseCollector
 	powerState   *prometheus.Desc
-	cpuHealth    *prometheus.Desc
-	memoryHealth *prometheus.Desc
+	cpuHealth    healthMetric
+	cpuFrequency *prometheus.Desc
+	cpuEnabled   *prometheus.Desc
+	memoryHealth healthMetric
+	watchdog     *prometheus.Desc
+	measuredBoot *prometheus.Desc
+	tpmRequired  *prometheus.Desc
 	readings     map[string]systemReading
-	firstCPUID   string
+	system       *systemState
 }
 
 type systemReading struct {
+	status  common.Status
+	cores   float64
+	name    string
+	model   string
+	odataID string
+
+	// Operating (or else maximum) frequency, 0 if not reported
+	frequency float64
+
+	// Whether the processor is enabled, only set if the BMC reports a state
+	enabled        float64
+	enabledPresent bool
+}
+
+// systemState holds the system-wide readings
+type systemState struct {
 	powerState     float64
-	health         float64
-	cores          float64
-	name           string
-	model          string
+	memoryHealth   common.Health
 	totalMemoryGiB string
+
+	// Host watchdog state, only set if the system reports a watchdog
+	watchdogEnabled float64
+	watchdogAction  string
+	watchdogPresent bool
+
+	// Measured boot state, only set if the system reports a trusted module
+	measuredBoot        float64
+	trustedModule       string
+	measuredBootPresent bool
+
+	// Whether a functioning trusted module is required to boot, only set if reported
+	tpmRequired        float64
+	tpmRequiredPresent bool
 }
 
 // NewSystemCollector creates a new SystemCollector
-func NewSystemCollector() *SystemCollector {
+func NewSystemCollector(opts Options) *SystemCollector {
 	return &SystemCollector{
-		BaseCollector: NewBaseCollector("ipmi", "system"),
-		powerState: prometheus.NewDesc(
+		BaseCollector: NewBaseCollector("ipmi", "system", opts),
+		powerState: opts.newDesc(
 			"ipmi_system_power_state",
 			"System power state (1 = On, 0 = Off)",
 			nil,
-			nil,
 		),
-		cpuHealth: prometheus.NewDesc(
+		cpuHealth: opts.newHealthMetric(
 			"ipmi_cpu_health",
-			"CPU health status (1 = OK, 0 = Warning/Critical, 2 = Not Available)",
-			[]string{"name", "model", "cores"},
-			nil,
+			"CPU health status",
+			opts.componentLabels("name", "model", "cores"),
 		),
-		memoryHealth: prometheus.NewDesc(
+		cpuFrequency: opts.newDesc(
+			"ipmi_cpu_frequency_mhz",
+			"CPU operating frequency in MHz, or the maximum rated frequency if the operating one is not reported",
+			opts.componentLabels("name"),
+		),
+		cpuEnabled: opts.newDesc(
+			"ipmi_cpu_enabled",
+			"CPU state (1 = Enabled, 0 = Disabled or otherwise unavailable)",
+			opts.componentLabels("name"),
+		),
+		memoryHealth: opts.newHealthMetric(
 			"ipmi_memory_health",
-			"Overall memory subsystem health status (1 = OK, 0 = Warning/Critical, 2 = Not Available)",
+			"Overall memory subsystem health status",
 			[]string{"total_gib"},
+		),
+		watchdog: opts.newDesc(
+			"ipmi_system_watchdog_enabled",
+			"Whether the host watchdog timer is enabled (1 = Enabled, 0 = Disabled), with the action taken on its expiration",
+			[]string{"timeout_action"},
+		),
+		measuredBoot: opts.newDesc(
+			"ipmi_system_measured_boot_enabled",
+			"Whether a trusted module (TPM) recording the boot measurements is enabled (1 = Enabled, 0 = Disabled or otherwise unavailable)",
+			[]string{"interface_type"},
+		),
+		tpmRequired: opts.newDesc(
+			"ipmi_system_trusted_module_required_to_boot",
+			"Whether the system only boots with a functioning trusted module (1 = Required, 0 = Disabled)",
 			nil,
 		),
 		readings: make(map[string]systemReading),
@@ -58,28 +114,52 @@ func (c *SystemCollector) Update(client *redfish.Client) error {
 	start := time.Now()
 	defer c.RecordScrapeTime(start)
 
+	// Clear previous readings
+	c.mutex.Lock()
+	c.readings = make(map[string]systemReading)
+	c.system = nil
+	c.mutex.Unlock()
+
 	// Get all systems
-	systems, err := client.Service.Systems()
+	systems, err := client.GetSystems()
 	if err != nil {
 		c.logger.Debug("failed to get systems", "error", err)
 		return nil
 	}
+	if len(systems) == 0 {
+		c.logger.Debug("no systems found")
+		return nil
+	}
 
 	// Get the first system
 	system := systems[0]
 
+	powerState := systemPowerState(system, func() gofishredfish.PowerState {
+		return c.chassisPowerState(client)
+	})
+
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	// Clear previous readings
-	c.readings = make(map[string]systemReading)
+	// Get memory health from system status
+	c.system = &systemState{
+		powerState:     powerState,
+		memoryHealth:   system.MemorySummary.Status.Health,
+		totalMemoryGiB: fmt.Sprintf("%.0f", float64(system.MemorySummary.TotalSystemMemoryGiB)),
+	}
 
-	// Get power state
-	powerState := 0.0
-	if system.PowerState == "On" {
-		powerState = 1.0
+	// The watchdog is embedded in the system, an absent one leaves all of its properties empty
+	watchdog := system.HostWatchdogTimer
+	if watchdog.FunctionEnabled || watchdog.TimeoutAction != "" || watchdog.Status.State != "" {
+		c.system.watchdogPresent = true
+		c.system.watchdogAction = watchdog.TimeoutAction
+		if watchdog.FunctionEnabled {
+			c.system.watchdogEnabled = 1.0
+		}
 	}
 
+	c.processTrustedModules(system)
+
 	// Get CPU information
 	processors, err := system.Processors()
 	if err != nil {
@@ -87,59 +167,95 @@ func (c *SystemCollector) Update(client *redfish.Client) error {
 		return nil
 	}
 
-	// Store first CPU ID for power state reporting
-	if len(processors) > 0 {
-		c.firstCPUID = processors[0].ID
-	}
-
 	// Process each CPU
 	for _, cpu := range processors {
-		health := 2.0 // Default to Not Available
-		if cpu.Status.Health != "" {
-			if cpu.Status.Health == "OK" {
-				health = 1.0
-			} else {
-				health = 0.0
+		reading := systemReading{
+			status:  cpu.Status,
+			cores:   float64(cpu.TotalCores),
+			name:    cpu.ID,
+			model:   cpu.Model,
+			odataID: cpu.ODataID,
+		}
+
+		// Prefer the current frequency over the rated one
+		reading.frequency = float64(cpu.OperatingSpeedMHz)
+		if reading.frequency == 0 {
+			reading.frequency = float64(cpu.MaxSpeedMHz)
+		}
+
+		if cpu.Status.State != "" {
+			reading.enabledPresent = true
+			if cpu.Status.State == common.EnabledState {
+				reading.enabled = 1.0
 			}
 		}
 
-		c.readings[cpu.ID] = systemReading{
-			powerState: powerState,
-			health:     health,
-			cores:      float64(cpu.TotalCores),
-			name:       cpu.ID,
-			model:      cpu.Model,
+		c.readings[cpu.ID] = reading
+	}
+
+	return nil
+}
+
+// processTrustedModules stores the measured boot state of the system. Redfish has no property
+// for measured boot itself, so it is considered enabled while a trusted module is, since that is
+// where the firmware records the measurements.
+func (c *SystemCollector) processTrustedModules(system *gofishredfish.ComputerSystem) {
+	for _, module := range system.TrustedModules {
+		if module.Status.State == "" && module.InterfaceType == "" {
+			continue
+		}
+		c.system.measuredBootPresent = true
+		if module.Status.State == common.EnabledState {
+			c.system.measuredBoot = 1.0
+			c.system.trustedModule = string(module.InterfaceType)
+			break
+		}
+		if c.system.trustedModule == "" {
+			c.system.trustedModule = string(module.InterfaceType)
 		}
 	}
 
-	// Get memory health from system status
-	memoryHealth := 2.0 // Default to Not Available
-	totalMemoryGiB := fmt.Sprintf("%.0f", float64(system.MemorySummary.TotalSystemMemoryGiB))
-	if system.MemorySummary.Status.Health != "" {
-		if system.MemorySummary.Status.Health == "OK" {
-			memoryHealth = 1.0
-		} else {
-			memoryHealth = 0.0
+	if required := system.Boot.TrustedModuleRequiredToBoot; required != "" {
+		c.system.tpmRequiredPresent = true
+		if required == gofishredfish.RequiredTrustedModuleRequiredToBoot {
+			c.system.tpmRequired = 1.0
 		}
 	}
+}
 
-	// Store memory health in the first CPU reading
-	if len(c.readings) > 0 {
-		reading := c.readings[c.firstCPUID]
-		reading.health = memoryHealth
-		reading.powerState = powerState
-		reading.totalMemoryGiB = totalMemoryGiB
-		c.readings[c.firstCPUID] = reading
+// systemPowerState returns 1 if a system is powered on and 0 otherwise, reading the power state
+// of the main chassis if the BMC only reports it there
+func systemPowerState(system *gofishredfish.ComputerSystem, chassisPowerState func() gofishredfish.PowerState) float64 {
+	state := system.PowerState
+	if state == "" {
+		state = chassisPowerState()
 	}
+	if state == gofishredfish.OnPowerState {
+		return 1.0
+	}
+	return 0.0
+}
 
-	return nil
+// chassisPowerState returns the power state of the main chassis, empty if it can't be read
+func (c *SystemCollector) chassisPowerState(client *redfish.Client) gofishredfish.PowerState {
+	chassis, err := client.GetMainChassis()
+	if err != nil {
+		c.logger.Debug("failed to get chassis for power state", "error", err)
+		return ""
+	}
+	return chassis.PowerState
 }
 
 // Describe describes all metrics this collector exposes
 func (c *SystemCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.powerState
-	ch <- c.cpuHealth
-	ch <- c.memoryHealth
+	c.DescribeHealth(ch, c.cpuHealth)
+	ch <- c.cpuFrequency
+	ch <- c.cpuEnabled
+	c.DescribeHealth(ch, c.memoryHealth)
+	ch <- c.watchdog
+	ch <- c.measuredBoot
+	ch <- c.tpmRequired
 	c.DescribeScrapeTime(ch)
 }
 
@@ -148,31 +264,73 @@ func (c *SystemCollector) Collect(ch chan<- prometheus.Metric) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	for _, reading := range c.readings {
-		// Report power state and memory health only once since they're system-wide
-		if reading.name == c.firstCPUID {
-			ch <- prometheus.MustNewConstMetric(
-				c.powerState,
+	// Report power state and memory health only once since they're system-wide
+	if c.system != nil {
+		c.Emit(
+			ch,
+			c.powerState,
+			prometheus.GaugeValue,
+			c.system.powerState,
+		)
+
+		c.CollectHealth(ch, c.memoryHealth, c.system.memoryHealth, c.system.totalMemoryGiB)
+
+		if c.system.watchdogPresent {
+			c.Emit(
+				ch,
+				c.watchdog,
 				prometheus.GaugeValue,
-				reading.powerState,
+				c.system.watchdogEnabled,
+				c.system.watchdogAction,
 			)
+		}
 
-			ch <- prometheus.MustNewConstMetric(
-				c.memoryHealth,
+		if c.system.measuredBootPresent {
+			c.Emit(
+				ch,
+				c.measuredBoot,
 				prometheus.GaugeValue,
-				reading.health,
-				reading.totalMemoryGiB,
+				c.system.measuredBoot,
+				c.system.trustedModule,
 			)
 		}
 
-		ch <- prometheus.MustNewConstMetric(
-			c.cpuHealth,
-			prometheus.GaugeValue,
-			reading.health,
+		if c.system.tpmRequiredPresent {
+			c.Emit(
+				ch,
+				c.tpmRequired,
+				prometheus.GaugeValue,
+				c.system.tpmRequired,
+			)
+		}
+	}
+
+	for _, reading := range c.readings {
+		c.CollectStatus(ch, c.cpuHealth, reading.status, c.componentValues(reading.odataID,
 			reading.name,
 			reading.model,
 			fmt.Sprintf("%d", int(reading.cores)),
-		)
+		)...)
+
+		if reading.frequency > 0 {
+			c.Emit(
+				ch,
+				c.cpuFrequency,
+				prometheus.GaugeValue,
+				reading.frequency,
+				c.componentValues(reading.odataID, reading.name)...,
+			)
+		}
+
+		if reading.enabledPresent {
+			c.Emit(
+				ch,
+				c.cpuEnabled,
+				prometheus.GaugeValue,
+				reading.enabled,
+				c.componentValues(reading.odataID, reading.name)...,
+			)
+		}
 	}
 
 	c.CollectScrapeTime(ch)
@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"testing"
+
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+// powerStateResources are a system reporting its power state and one that leaves it to its chassis,
+// as OpenBMC does
+var powerStateResources = map[string]string{
+	"/redfish/v1/Systems/1": `{
+		"@odata.id": "/redfish/v1/Systems/1",
+		"Id": "1",
+		"PowerState": "Off"
+	}`,
+	"/redfish/v1/Systems/system": `{
+		"@odata.id": "/redfish/v1/Systems/system",
+		"Id": "system"
+	}`,
+	"/redfish/v1/Chassis/chassis": `{
+		"@odata.id": "/redfish/v1/Chassis/chassis",
+		"Id": "chassis",
+		"PowerState": "On"
+	}`,
+}
+
+func TestSystemPowerState(t *testing.T) {
+	client := fakeClient{resources: powerStateResources}
+	chassis, err := gofishredfish.GetChassis(client, "/redfish/v1/Chassis/chassis")
+	if err != nil {
+		t.Fatalf("failed to read the chassis: %v", err)
+	}
+
+	for _, tt := range []struct {
+		system        string
+		want          float64
+		chassisLookup bool
+	}{
+		{"/redfish/v1/Systems/1", 0, false},
+		{"/redfish/v1/Systems/system", 1, true},
+	} {
+		system, err := gofishredfish.GetComputerSystem(client, tt.system)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", tt.system, err)
+		}
+
+		looked := false
+		got := systemPowerState(system, func() gofishredfish.PowerState {
+			looked = true
+			return chassis.PowerState
+		})
+		if got != tt.want || looked != tt.chassisLookup {
+			t.Errorf("%s: got power state %v with chassis lookup %v, want %v with %v", tt.system, got, looked, tt.want, tt.chassisLookup)
+		}
+	}
+}
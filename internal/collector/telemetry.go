@@ -5,6 +5,8 @@ import (
 
 	"github.com/mllnd/sherlock/internal/redfish"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
 )
 
 // TelemetryCollector collects power consumption metrics
@@ -12,17 +14,17 @@ type TelemetryCollector struct {
 	BaseCollector
 	powerConsumption *prometheus.Desc
 	reading          float64
+	present          bool
 }
 
 // NewTelemetryCollector creates a new TelemetryCollector
-func NewTelemetryCollector() *TelemetryCollector {
+func NewTelemetryCollector(opts Options) *TelemetryCollector {
 	return &TelemetryCollector{
-		BaseCollector: NewBaseCollector("ipmi", "telemetry"),
-		powerConsumption: prometheus.NewDesc(
+		BaseCollector: NewBaseCollector("ipmi", "telemetry", opts),
+		powerConsumption: opts.newDesc(
 			"ipmi_telemetry_power_consumption_watts",
 			"Current power consumption in watts",
 			nil,
-			nil,
 		),
 	}
 }
@@ -35,33 +37,56 @@ func (c *TelemetryCollector) Update(client *redfish.Client) error {
 	// Clear previous reading
 	c.mutex.Lock()
 	c.reading = 0
+	c.present = false
 	c.mutex.Unlock()
 
-	// Try to get power consumption from chassis
-	chassis, err := client.GetMainChassis()
+	// Try to get power consumption from the requested chassis
+	chassis, err := c.getChassis(client)
 	if err != nil {
-		c.logger.Debug("failed to get main chassis", "error", err)
+		c.logger.Debug("failed to get chassis", "error", err)
 		return nil
 	}
 
-	power, err := chassis.Power()
+	power, err := c.fetchPower(chassis, "PowerControl")
 	if err != nil {
 		c.logger.Debug("failed to get power information", "error", err)
-		return nil
+		return c.unsupported(err)
 	}
 
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
 	// Process power control readings
+	var reading float64
 	for _, pc := range power.PowerControl {
 		if pc.PowerConsumedWatts > 0 {
-			c.reading = float64(pc.PowerConsumedWatts)
-			c.logger.Debug("updated power consumption", "watts", c.reading)
+			reading = float64(pc.PowerConsumedWatts)
 			break // Take the first valid reading
 		}
 	}
 
+	// Fall back to the chassis power sensor, whose units vary between BMCs
+	if reading == 0 {
+		reading = c.sensorPower(chassis)
+	}
+
+	// Report a genuine zero when a present power control has no consumption
+	present := false
+	if c.emitZero() && reading == 0 {
+		for _, pc := range power.PowerControl {
+			if pc.Status.State != common.AbsentState {
+				present = true
+				break
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.reading = reading
+	c.present = present
+	if reading > 0 {
+		c.logger.Debug("updated power consumption", "watts", reading)
+	}
+
 	return nil
 }
 
@@ -76,8 +101,9 @@ func (c *TelemetryCollector) Collect(ch chan<- prometheus.Metric) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if c.reading > 0 {
-		ch <- prometheus.MustNewConstMetric(
+	if c.reading > 0 || c.present {
+		c.Emit(
+			ch,
 			c.powerConsumption,
 			prometheus.GaugeValue,
 			c.reading,
@@ -86,3 +112,28 @@ func (c *TelemetryCollector) Collect(ch chan<- prometheus.Metric) {
 
 	c.CollectScrapeTime(ch)
 }
+
+// sensorPower returns the reading in watts of the first power sensor of the Sensors collection
+// of a chassis that measures the whole chassis, or 0 if there is none
+func (c *TelemetryCollector) sensorPower(chassis *gofishredfish.Chassis) float64 {
+	sensors, err := chassis.Sensors()
+	if err != nil {
+		c.logger.Debug("failed to get sensors", "chassis", chassis.ID, "error", err)
+		return 0
+	}
+
+	for _, sensor := range sensors {
+		if sensor.ReadingType != gofishredfish.PowerReadingType || sensor.PhysicalContext != common.ChassisPhysicalContext {
+			continue
+		}
+		watts, ok := normalizeReading(float64(sensor.Reading), sensor.ReadingUnits, unitWatts)
+		if !ok {
+			c.logger.Debug("skipping sensor with unsupported units", "sensor", sensor.Name, "units", sensor.ReadingUnits)
+			continue
+		}
+		if watts > 0 {
+			return watts
+		}
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"testing"
+
+	gofishredfish "github.com/stmcginnis/gofish/redfish"
+)
+
+func TestTelemetrySensorPowerInKilowatts(t *testing.T) {
+	client := fakeClient{resources: map[string]string{
+		"/redfish/v1/Chassis/1": `{
+			"@odata.id": "/redfish/v1/Chassis/1",
+			"Id": "1",
+			"Sensors": {"@odata.id": "/redfish/v1/Chassis/1/Sensors"}
+		}`,
+		"/redfish/v1/Chassis/1/Sensors": `{
+			"Members": [
+				{"@odata.id": "/redfish/v1/Chassis/1/Sensors/PSU1"},
+				{"@odata.id": "/redfish/v1/Chassis/1/Sensors/Total"}
+			]
+		}`,
+		"/redfish/v1/Chassis/1/Sensors/PSU1": `{
+			"@odata.id": "/redfish/v1/Chassis/1/Sensors/PSU1",
+			"Name": "PSU1 Input Power",
+			"ReadingType": "Power",
+			"PhysicalContext": "PowerSupply",
+			"Reading": 0.4,
+			"ReadingUnits": "kW"
+		}`,
+		"/redfish/v1/Chassis/1/Sensors/Total": `{
+			"@odata.id": "/redfish/v1/Chassis/1/Sensors/Total",
+			"Name": "Total Power",
+			"ReadingType": "Power",
+			"PhysicalContext": "Chassis",
+			"Reading": 1.25,
+			"ReadingUnits": "kW"
+		}`,
+	}}
+
+	chassis, err := gofishredfish.GetChassis(client, "/redfish/v1/Chassis/1")
+	if err != nil {
+		t.Fatalf("failed to read the chassis: %v", err)
+	}
+
+	c := NewTelemetryCollector(Options{})
+	if got := c.sensorPower(chassis); got != 1250 {
+		t.Errorf("got %v W, want the chassis sensor converted to 1250 W", got)
+	}
+}
@@ -0,0 +1,43 @@
+package collector
+
+// Base units of the metrics, as UCUM codes used by the ReadingUnits of Redfish sensors
+const (
+	unitCelsius = "Cel"
+	unitVolts   = "V"
+	unitPercent = "%"
+	unitWatts   = "W"
+)
+
+// unitConversions converts readings reported in other units to each base unit, by reported unit
+var unitConversions = map[string]map[string]func(float64) float64{
+	unitCelsius: {
+		"[degF]": fahrenheitToCelsius,
+		"K":      func(v float64) float64 { return v - 273.15 },
+	},
+	unitVolts: {
+		"mV": func(v float64) float64 { return v / 1000 },
+		"kV": func(v float64) float64 { return v * 1000 },
+	},
+	unitWatts: {
+		"mW": func(v float64) float64 { return v / 1000 },
+		"kW": func(v float64) float64 { return v * 1000 },
+	},
+}
+
+// normalizeReading converts a reading in the reported units to the given base unit. Readings
+// without units are taken to be in the base unit. Readings in units that can't be converted are
+// rejected rather than exported with a wrong value.
+func normalizeReading(value float64, units, base string) (float64, bool) {
+	if units == "" || units == base {
+		return value, true
+	}
+	if convert, ok := unitConversions[base][units]; ok {
+		return convert(value), true
+	}
+	return 0, false
+}
+
+// fahrenheitToCelsius converts a temperature in degrees Fahrenheit to degrees Celsius
+func fahrenheitToCelsius(v float64) float64 {
+	return (v - 32) * 5 / 9
+}
@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeReading(t *testing.T) {
+	for _, tt := range []struct {
+		value float64
+		units string
+		base  string
+		want  float64
+		ok    bool
+	}{
+		{42, "", unitCelsius, 42, true},
+		{42, unitCelsius, unitCelsius, 42, true},
+		{212, "[degF]", unitCelsius, 100, true},
+		{300, "K", unitCelsius, 26.85, true},
+		{12, unitVolts, unitVolts, 12, true},
+		{3300, "mV", unitVolts, 3.3, true},
+		{0.23, "kV", unitVolts, 230, true},
+		{45, "%", unitPercent, 45, true},
+		{350, unitWatts, unitWatts, 350, true},
+		{1.2, "kW", unitWatts, 1200, true},
+		{500, "mW", unitWatts, 0.5, true},
+		{5, "A", unitVolts, 0, false},
+		{1.2, "kW", unitCelsius, 0, false},
+	} {
+		got, ok := normalizeReading(tt.value, tt.units, tt.base)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("normalizeReading(%v, %q, %q) = %v, %v, want %v, %v", tt.value, tt.units, tt.base, got, ok, tt.want, tt.ok)
+		}
+	}
+}
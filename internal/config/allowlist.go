@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// allowlistEntries returns the hosts and CIDR ranges targets are restricted to, defaulting to
+// the hosts of the config file
+func (c *Config) allowlistEntries() []string {
+	var entries []string
+	for _, entry := range strings.Split(c.TargetAllowlist, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) > 0 {
+		return entries
+	}
+
+	for _, target := range c.Targets {
+		entries = append(entries, target.Host)
+	}
+	return entries
+}
+
+// TargetAllowed reports whether the given target may be scraped. Every target is allowed unless
+// RESTRICT_TARGETS is set. CIDR ranges only match targets given as IP addresses, since a host name
+// could resolve to a different address by the time the exporter connects.
+func (c *Config) TargetAllowed(target string) bool {
+	if !c.RestrictTargets {
+		return true
+	}
+
+	host, ok := targetHost(target)
+	if !ok {
+		return false
+	}
+	ip := net.ParseIP(host)
+
+	for _, entry := range c.allowlistEntries() {
+		if entry == target || strings.EqualFold(entry, host) {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil && ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// targetHost returns the host the exporter connects to for a target, which must be a host with an
+// optional numeric port. Anything else, such as user info that would move the host of the URL the
+// exporter builds from the target, is rejected.
+func targetHost(target string) (string, bool) {
+	u, err := url.Parse("https://" + target)
+	if err != nil || u.User != nil || u.Path != "" || u.RawQuery != "" || u.ForceQuery || u.Fragment != "" {
+		return "", false
+	}
+	if u.Hostname() == "" {
+		return "", false
+	}
+	if port := u.Port(); port != "" {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return "", false
+		}
+	} else if strings.HasSuffix(u.Host, ":") {
+		return "", false
+	}
+	return u.Hostname(), true
+}
+
+// validateAllowlist checks that restricting targets leaves any target allowed
+func (c *Config) validateAllowlist() error {
+	if !c.RestrictTargets {
+		return nil
+	}
+	if len(c.allowlistEntries()) == 0 {
+		return fmt.Errorf("RESTRICT_TARGETS requires TARGET_ALLOWLIST or targets in the config file")
+	}
+	for _, entry := range c.allowlistEntries() {
+		if strings.Contains(entry, "/") {
+			if _, _, err := net.ParseCIDR(entry); err != nil {
+				return fmt.Errorf("invalid TARGET_ALLOWLIST entry %q: %v", entry, err)
+			}
+		}
+	}
+	return nil
+}
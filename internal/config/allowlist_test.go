@@ -0,0 +1,62 @@
+package config
+
+import "testing"
+
+func TestTargetAllowed(t *testing.T) {
+	c := &Config{
+		RestrictTargets: true,
+		TargetAllowlist: "bmc1.example.com, 10.0.0.0/24, bmc2.example.com:8443",
+	}
+
+	for _, tt := range []struct {
+		target string
+		want   bool
+	}{
+		{"bmc1.example.com", true},
+		{"BMC1.example.com", true},
+		{"bmc1.example.com:443", true},
+		{"bmc2.example.com:8443", true},
+		{"10.0.0.17", true},
+		{"10.0.0.17:443", true},
+		{"10.0.1.17", false},
+		{"[::1]:443", false},
+		{"evil.example", false},
+
+		// Targets that would make the exporter connect somewhere else
+		{"bmc1.example.com:443@evil.example", false},
+		{"bmc1.example.com@evil.example", false},
+		{"bmc1.example.com/redfish", false},
+		{"bmc1.example.com?x=1", false},
+		{"bmc1.example.com#x", false},
+		{"bmc1.example.com:https", false},
+		{"bmc1.example.com:99999", false},
+		{"bmc1.example.com:", false},
+		{"", false},
+	} {
+		if got := c.TargetAllowed(tt.target); got != tt.want {
+			t.Errorf("TargetAllowed(%q) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestTargetAllowedDefaultsToConfiguredTargets(t *testing.T) {
+	c := &Config{
+		RestrictTargets: true,
+		Targets:         []TargetConfig{{Host: "bmc1.example.com"}},
+	}
+	if !c.TargetAllowed("bmc1.example.com") || c.TargetAllowed("bmc2.example.com") {
+		t.Error("the allowlist doesn't default to the hosts of the config file")
+	}
+	if !(&Config{}).TargetAllowed("bmc1.example.com:443@evil.example") {
+		t.Error("targets are restricted without RESTRICT_TARGETS")
+	}
+}
+
+func TestValidateAllowlist(t *testing.T) {
+	if err := (&Config{RestrictTargets: true}).validateAllowlist(); err == nil {
+		t.Error("RESTRICT_TARGETS without any allowed target passed validation")
+	}
+	if err := (&Config{RestrictTargets: true, TargetAllowlist: "10.0.0.0/33"}).validateAllowlist(); err == nil {
+		t.Error("an invalid CIDR range passed validation")
+	}
+}
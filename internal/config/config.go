@@ -3,8 +3,12 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 // Config holds the application configuration
@@ -15,29 +19,343 @@ type Config struct {
 	RedfishPassword string
 	RedfishInsecure bool
 
+	// TLS settings of the connections to the BMCs
+	TLSMinVersion   string
+	TLSCipherSuites string
+
+	// Resolve targets to their IP address so aliases share a client
+	CanonicalizeTargets bool
+
+	// Restrict scrapes to the targets of the allowlist, defaulting to the config file targets
+	RestrictTargets bool
+	TargetAllowlist string
+
+	// Interval of the service root pings keeping idle BMC sessions alive (0 = disabled)
+	KeepAliveInterval time.Duration
+
 	// HTTP server settings
 	ListenAddress string
 	MetricsPath   string
 
+	// Credentials protecting the administrative endpoints (disabled when unset)
+	AdminUsername string
+	AdminPassword string
+
 	// Collection settings
 	ScrapeInterval time.Duration
 	Timeout        time.Duration
+	ConnectTimeout time.Duration
+
+	// Retry settings for requests rejected by the BMC
+	MaxRetries   int
+	MaxRetryWait time.Duration
+
+	// Maximum number of redirects followed before giving up
+	MaxRedirects int
+
+	// Retries of thermal and power fetches that succeed without readings
+	EmptyRetries    int
+	EmptyRetryDelay time.Duration
+
+	// Reconnection settings smoothing session renewals across targets
+	MaxConcurrentReconnects int
+	ReconnectJitter         time.Duration
+
+	// Regular expressions for filtering sensors and fans by name
+	SensorInclude string
+	SensorExclude string
+
+	// Read sensors, fans and power supplies from every chassis concurrently
+	AllChassis     bool
+	ChassisWorkers int
+
+	// Maximum length of string label values (0 = no limit)
+	MaxLabelLength int
+
+	// Maximum number of series each collector emits per scrape (0 = no limit)
+	MaxSeries int
+
+	// Window within which critical log entries are counted (0 = disabled)
+	CriticalEventsWindow time.Duration
+
+	// Number of scrapes a sensor reading must stay unchanged to be flagged as stale (0 = disabled)
+	StaleScrapes int
+
+	// Expose unchanged gauge readings with the timestamp they were last exposed with
+	ChangesOnly   bool
+	ChangeEpsilon float64
+	ChangeMaxAge  time.Duration
+
+	// Plausible ranges of temperature and voltage readings as "min:max" (empty = no check)
+	TemperatureRange string
+	VoltageRange     string
+
+	// Whether collectors the BMC doesn't support fail the scrape (strict) or are ignored (lenient)
+	UnsupportedCollectors string
+
+	// Number of recent scrapes per target the success ratio is computed over
+	SuccessRatioWindow int
+
+	// Label power supplies as "PSU N" instead of by their Redfish name
+	PSUSyntheticNames bool
+
+	// Add the Redfish resource path as an odata_id label to per-component metrics
+	ODataIDLabel bool
+
+	// Comma-separated collectors that emit explicit zero readings for present components
+	EmitZero string
+
+	// Targets loaded from the config file
+	Targets []TargetConfig
+
+	// Target groups with shared settings, from the config file
+	Groups []TargetGroup
+
+	// Chassis ID, "main" or "auto" used by each chassis-based collector, from the config file
+	Chassis map[string]string
+
+	// Metric metadata overrides by metric name, from the config file
+	Metrics map[string]MetricConfig
+
+	// Static labels applied to every exported metric
+	Labels Labels
+
+	// Refuse to start with the built-in default credentials
+	Strict bool
+}
+
+// Handling of collectors the BMC doesn't support
+const (
+	UnsupportedLenient = "lenient"
+	UnsupportedStrict  = "strict"
+)
+
+// Built-in default Redfish credentials, only meant for local testing
+const (
+	defaultUsername = "admin"
+	defaultPassword = "password"
+)
+
+// labelNameRE matches valid Prometheus label names
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Labels is a set of static key=value labels that can be used as a repeatable flag
+type Labels map[string]string
+
+// String returns the labels as a comma-separated list of key=value pairs
+func (l Labels) String() string {
+	pairs := make([]string, 0, len(l))
+	for key, value := range l {
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set parses a single key=value pair and adds it to the labels
+func (l Labels) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("label must be in key=value format: %q", value)
+	}
+	if !labelNameRE.MatchString(key) || strings.HasPrefix(key, "__") {
+		return fmt.Errorf("invalid label name: %q", key)
+	}
+	if _, exists := l[key]; exists {
+		return fmt.Errorf("label %q specified more than once", key)
+	}
+	l[key] = val
+	return nil
+}
+
+// TargetConfig holds the settings for a single target in the config file
+type TargetConfig struct {
+	Host       string `yaml:"host"`
+	Timeout    string `yaml:"timeout"`
+	Aggregator bool   `yaml:"aggregator"`
+
+	// IPMIFallback collects basic metrics over IPMI-over-LAN when the target has no Redfish service
+	IPMIFallback bool `yaml:"ipmi_fallback"`
+
+	// TLSMinVersion overrides the minimum TLS version, e.g. "1.0" for legacy BMCs
+	TLSMinVersion string `yaml:"tls_min_version"`
+
+	// PinAddress keeps each session on the backend the host first resolved to
+	PinAddress bool `yaml:"pin_address"`
+
+	// BasePath is the path the Redfish API is served under, for BMCs behind an API gateway
+	BasePath string `yaml:"base_path"`
+
+	// ODataVersion and Accept set the OData-Version and Accept headers of every request, for
+	// firmware that rejects requests without them or with the defaults
+	ODataVersion string `yaml:"odata_version"`
+	Accept       string `yaml:"accept"`
+
+	// Group is the name of the group whose shared settings apply to the target
+	Group string `yaml:"group"`
+}
+
+// fileConfig is the on-disk layout of the config file
+type fileConfig struct {
+	Targets []TargetConfig          `yaml:"targets"`
+	Groups  []TargetGroup           `yaml:"groups"`
+	Chassis map[string]string       `yaml:"chassis"`
+	Metrics map[string]MetricConfig `yaml:"metrics"`
+}
+
+// MetricConfig holds the metadata overrides of a metric from the config file
+type MetricConfig struct {
+	Help string `yaml:"help"`
+
+	// Unit is rejected by Validate: metric descriptors carry no unit, and the exposition handler
+	// never writes one
+	Unit string `yaml:"unit"`
 }
 
 // NewConfig creates a new Config with values from environment or defaults
 func NewConfig() *Config {
 	return &Config{
 		RedfishHost:     getEnv("REDFISH_HOST", "http://localhost:5000"),
-		RedfishUsername: getEnv("REDFISH_USERNAME", "admin"),
-		RedfishPassword: getEnv("REDFISH_PASSWORD", "password"),
+		RedfishUsername: getEnv("REDFISH_USERNAME", defaultUsername),
+		RedfishPassword: getEnv("REDFISH_PASSWORD", defaultPassword),
 		RedfishInsecure: getBoolEnv("REDFISH_INSECURE", true),
 
+		TLSMinVersion:   getEnv("TLS_MIN_VERSION", "1.2"),
+		TLSCipherSuites: getEnv("TLS_CIPHER_SUITES", ""),
+
+		CanonicalizeTargets: getBoolEnv("CANONICALIZE_TARGETS", false),
+
+		RestrictTargets: getBoolEnv("RESTRICT_TARGETS", false),
+		TargetAllowlist: getEnv("TARGET_ALLOWLIST", ""),
+
+		KeepAliveInterval: getDurationEnv("KEEPALIVE_INTERVAL", 0),
+
 		ListenAddress: getEnv("LISTEN_ADDRESS", "localhost:9290"),
 		MetricsPath:   getEnv("METRICS_PATH", "/metrics"),
 
+		AdminUsername: getEnv("ADMIN_USERNAME", ""),
+		AdminPassword: getEnv("ADMIN_PASSWORD", ""),
+
 		ScrapeInterval: getDurationEnv("SCRAPE_INTERVAL", 60*time.Second),
 		Timeout:        getDurationEnv("TIMEOUT", 30*time.Second),
+		ConnectTimeout: getDurationEnv("CONNECT_TIMEOUT", 5*time.Second),
+
+		MaxRetries:   getIntEnv("MAX_RETRIES", 2),
+		MaxRetryWait: getDurationEnv("MAX_RETRY_WAIT", 10*time.Second),
+		MaxRedirects: getIntEnv("MAX_REDIRECTS", 10),
+
+		EmptyRetries:    getIntEnv("EMPTY_RETRIES", 0),
+		EmptyRetryDelay: getDurationEnv("EMPTY_RETRY_DELAY", 500*time.Millisecond),
+
+		MaxConcurrentReconnects: getIntEnv("MAX_CONCURRENT_RECONNECTS", 4),
+		ReconnectJitter:         getDurationEnv("RECONNECT_JITTER", 2*time.Second),
+
+		SensorInclude: getEnv("SENSOR_INCLUDE", ""),
+		SensorExclude: getEnv("SENSOR_EXCLUDE", ""),
+
+		AllChassis:     getBoolEnv("ALL_CHASSIS", false),
+		ChassisWorkers: getIntEnv("CHASSIS_WORKERS", 4),
+
+		MaxLabelLength:    getIntEnv("MAX_LABEL_LENGTH", 0),
+		MaxSeries:         getIntEnv("MAX_SERIES", 10000),
+		PSUSyntheticNames: getBoolEnv("PSU_SYNTHETIC_NAMES", false),
+		ODataIDLabel:      getBoolEnv("ODATA_ID_LABEL", false),
+		EmitZero:          getEnv("EMIT_ZERO", ""),
+
+		SuccessRatioWindow: getIntEnv("SUCCESS_RATIO_WINDOW", 20),
+		StaleScrapes:       getIntEnv("STALE_SCRAPES", 0),
+
+		CriticalEventsWindow: getDurationEnv("CRITICAL_EVENTS_WINDOW", 0),
+
+		ChangesOnly:   getBoolEnv("EMIT_CHANGES_ONLY", false),
+		ChangeEpsilon: getFloatEnv("CHANGE_EPSILON", 0),
+		ChangeMaxAge:  getDurationEnv("CHANGE_MAX_AGE", 4*time.Minute),
+
+		TemperatureRange: getEnv("TEMPERATURE_RANGE", "-50:150"),
+		VoltageRange:     getEnv("VOLTAGE_RANGE", "-600:600"),
+
+		UnsupportedCollectors: getEnv("UNSUPPORTED_COLLECTORS", UnsupportedLenient),
+	}
+}
+
+// LoadFile reads the multi-target config file at the given path into the config
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	data, err = expandEnv(data)
+	if err != nil {
+		return fmt.Errorf("failed to expand config file: %v", err)
+	}
+
+	var file fileConfig
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	c.Targets = file.Targets
+	c.Groups = file.Groups
+	c.addGroupMembers()
+	c.Chassis = file.Chassis
+	c.Metrics = file.Metrics
+	return nil
+}
+
+// MetricHelp returns the help text overrides by metric name
+func (c *Config) MetricHelp() map[string]string {
+	help := make(map[string]string, len(c.Metrics))
+	for name, metric := range c.Metrics {
+		help[name] = metric.Help
+	}
+	return help
+}
+
+// envReferenceRE matches ${ENV_VAR} references in the config file
+var envReferenceRE = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${ENV_VAR} references with the variable's value, failing on unset variables
+func expandEnv(data []byte) ([]byte, error) {
+	var missing []string
+	expanded := envReferenceRE.ReplaceAllFunc(data, func(reference []byte) []byte {
+		name := string(envReferenceRE.FindSubmatch(reference)[1])
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return []byte(value)
+	})
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// Target returns the config file settings for the given host, if any
+func (c *Config) Target(host string) (TargetConfig, bool) {
+	for _, target := range c.Targets {
+		if target.Host == host {
+			return target, true
+		}
+	}
+	return TargetConfig{}, false
+}
+
+// TimeoutFor returns the scrape timeout for the given host, falling back to its group's
+// timeout and then the global default
+func (c *Config) TimeoutFor(host string) time.Duration {
+	if target, ok := c.Target(host); ok && target.Timeout != "" {
+		if d, err := time.ParseDuration(target.Timeout); err == nil {
+			return d
+		}
 	}
+	if group, ok := c.Group(host); ok && group.Timeout != "" {
+		if d, err := time.ParseDuration(group.Timeout); err == nil {
+			return d
+		}
+	}
+	return c.Timeout
 }
 
 // getEnv retrieves an environment variable or returns a default value
@@ -60,6 +378,30 @@ func getBoolEnv(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getIntEnv retrieves an integer environment variable or returns a default value
+func getIntEnv(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return defaultValue
+		}
+		return i
+	}
+	return defaultValue
+}
+
+// getFloatEnv retrieves a floating point environment variable or returns a default value
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return defaultValue
+		}
+		return f
+	}
+	return defaultValue
+}
+
 // getDurationEnv retrieves a duration environment variable or returns a default value
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value, exists := os.LookupEnv(key); exists {
@@ -72,6 +414,66 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// DefaultCredentials reports whether the Redfish credentials are the built-in defaults
+func (c *Config) DefaultCredentials() bool {
+	return c.RedfishUsername == defaultUsername && c.RedfishPassword == defaultPassword
+}
+
+// AdminEnabled reports whether credentials for the administrative endpoints are configured
+func (c *Config) AdminEnabled() bool {
+	return c.AdminUsername != "" && c.AdminPassword != ""
+}
+
+// chassisCollectors are the chassis-based collectors, which support per-collector chassis selection
+var chassisCollectors = map[string]bool{
+	"fan":       true,
+	"power":     true,
+	"sensor":    true,
+	"telemetry": true,
+}
+
+// emitZeroCollectors are the collectors that leave zero readings out unless asked to emit them.
+// The other chassis-based collectors always export the zero readings of their components.
+var emitZeroCollectors = map[string]bool{
+	"telemetry": true,
+}
+
+// EmitZeroCollectors returns the collectors listed in EMIT_ZERO
+func (c *Config) EmitZeroCollectors() []string {
+	var names []string
+	for _, name := range strings.Split(c.EmitZero, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ParseRange parses a "min:max" range of readings. An empty range disables the check and is
+// returned as 0:0.
+func ParseRange(value string) (float64, float64, error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+
+	minValue, maxValue, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("range %q must be in min:max format", value)
+	}
+	low, err := strconv.ParseFloat(strings.TrimSpace(minValue), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid minimum in range %q: %v", value, err)
+	}
+	high, err := strconv.ParseFloat(strings.TrimSpace(maxValue), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid maximum in range %q: %v", value, err)
+	}
+	if low >= high {
+		return 0, 0, fmt.Errorf("minimum of range %q must be below its maximum", value)
+	}
+	return low, high, nil
+}
+
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
 	if c.RedfishHost == "" {
@@ -83,5 +485,118 @@ func (c *Config) Validate() error {
 	if c.RedfishPassword == "" {
 		return fmt.Errorf("REDFISH_PASSWORD must be set")
 	}
-	return nil
+	if _, err := parseTLSVersion(c.TLSMinVersion); err != nil {
+		return fmt.Errorf("invalid TLS_MIN_VERSION: %v", err)
+	}
+	if _, err := parseCipherSuites(c.TLSCipherSuites); err != nil {
+		return fmt.Errorf("invalid TLS_CIPHER_SUITES: %v", err)
+	}
+	if c.Strict && c.DefaultCredentials() {
+		return fmt.Errorf("REDFISH_USERNAME and REDFISH_PASSWORD must not be the built-in defaults in strict mode")
+	}
+	if _, err := regexp.Compile(c.SensorInclude); err != nil {
+		return fmt.Errorf("invalid SENSOR_INCLUDE: %v", err)
+	}
+	if _, err := regexp.Compile(c.SensorExclude); err != nil {
+		return fmt.Errorf("invalid SENSOR_EXCLUDE: %v", err)
+	}
+	for _, name := range c.EmitZeroCollectors() {
+		if !emitZeroCollectors[name] {
+			return fmt.Errorf("invalid EMIT_ZERO collector %q", name)
+		}
+	}
+	for name, metric := range c.Metrics {
+		if metric.Unit != "" {
+			return fmt.Errorf("invalid metric %q in config file: unit overrides are not supported", name)
+		}
+	}
+	for name, id := range c.Chassis {
+		if !chassisCollectors[name] {
+			return fmt.Errorf("invalid chassis collector %q in config file", name)
+		}
+		if id == "" {
+			return fmt.Errorf("empty chassis for collector %q in config file", name)
+		}
+	}
+	for name, metric := range c.Metrics {
+		if metric.Help == "" {
+			return fmt.Errorf("empty help text for metric %q in config file", name)
+		}
+	}
+	if c.ConnectTimeout <= 0 {
+		return fmt.Errorf("CONNECT_TIMEOUT must be positive")
+	}
+	if c.MaxConcurrentReconnects < 1 {
+		return fmt.Errorf("MAX_CONCURRENT_RECONNECTS must be at least 1")
+	}
+	if c.ReconnectJitter < 0 {
+		return fmt.Errorf("RECONNECT_JITTER must not be negative")
+	}
+	if c.EmptyRetries < 0 {
+		return fmt.Errorf("EMPTY_RETRIES must not be negative")
+	}
+	if c.MaxSeries < 0 {
+		return fmt.Errorf("MAX_SERIES must not be negative")
+	}
+	if c.CriticalEventsWindow < 0 {
+		return fmt.Errorf("CRITICAL_EVENTS_WINDOW must not be negative")
+	}
+	if c.StaleScrapes < 0 {
+		return fmt.Errorf("STALE_SCRAPES must not be negative")
+	}
+	if c.StaleScrapes > 0 && c.ScrapeInterval <= 0 {
+		return fmt.Errorf("SCRAPE_INTERVAL must be positive when STALE_SCRAPES is set")
+	}
+	if c.ChangeEpsilon < 0 {
+		return fmt.Errorf("CHANGE_EPSILON must not be negative")
+	}
+	if c.ChangeMaxAge <= 0 {
+		return fmt.Errorf("CHANGE_MAX_AGE must be positive")
+	}
+	if _, _, err := ParseRange(c.TemperatureRange); err != nil {
+		return fmt.Errorf("invalid TEMPERATURE_RANGE: %v", err)
+	}
+	if _, _, err := ParseRange(c.VoltageRange); err != nil {
+		return fmt.Errorf("invalid VOLTAGE_RANGE: %v", err)
+	}
+	if c.KeepAliveInterval < 0 {
+		return fmt.Errorf("KEEPALIVE_INTERVAL must not be negative")
+	}
+	if c.UnsupportedCollectors != UnsupportedLenient && c.UnsupportedCollectors != UnsupportedStrict {
+		return fmt.Errorf("UNSUPPORTED_COLLECTORS must be %q or %q", UnsupportedLenient, UnsupportedStrict)
+	}
+	if c.SuccessRatioWindow < 1 {
+		return fmt.Errorf("SUCCESS_RATIO_WINDOW must be at least 1")
+	}
+	if c.MaxRedirects < 1 {
+		return fmt.Errorf("MAX_REDIRECTS must be at least 1")
+	}
+	if c.ChassisWorkers < 1 {
+		return fmt.Errorf("CHASSIS_WORKERS must be at least 1")
+	}
+	for _, target := range c.Targets {
+		if target.Host == "" {
+			return fmt.Errorf("config file target is missing a host")
+		}
+		if _, ok := c.Labels["subordinate"]; ok && target.Aggregator {
+			return fmt.Errorf("static label \"subordinate\" collides with aggregator target %s", target.Host)
+		}
+		if target.Timeout != "" {
+			if d, err := time.ParseDuration(target.Timeout); err != nil || d <= 0 {
+				return fmt.Errorf("invalid timeout %q for target %s", target.Timeout, target.Host)
+			}
+		}
+		if target.BasePath != "" && !strings.HasPrefix(target.BasePath, "/") {
+			return fmt.Errorf("invalid base_path %q for target %s: must start with /", target.BasePath, target.Host)
+		}
+		if target.TLSMinVersion != "" {
+			if _, err := parseTLSVersion(target.TLSMinVersion); err != nil {
+				return fmt.Errorf("invalid tls_min_version for target %s: %v", target.Host, err)
+			}
+		}
+	}
+	if err := c.validateGroups(); err != nil {
+		return err
+	}
+	return c.validateAllowlist()
 }
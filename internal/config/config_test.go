@@ -0,0 +1,37 @@
+package config
+
+import "testing"
+
+func TestValidateEmitZero(t *testing.T) {
+	for _, tt := range []struct {
+		emitZero string
+		valid    bool
+	}{
+		{"", true},
+		{"telemetry", true},
+		{" telemetry ", true},
+		{"fan", false},
+		{"telemetry,power", false},
+	} {
+		c := NewConfig()
+		c.RedfishPassword = "secret"
+		c.EmitZero = tt.emitZero
+		if err := c.Validate(); (err == nil) != tt.valid {
+			t.Errorf("EMIT_ZERO=%q: got error %v, want valid %v", tt.emitZero, err, tt.valid)
+		}
+	}
+}
+
+func TestValidateRejectsUnitOverrides(t *testing.T) {
+	c := NewConfig()
+	c.RedfishPassword = "secret"
+	c.Metrics = map[string]MetricConfig{"ipmi_fan_speed_rpm": {Help: "Fan speed"}}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("a help override failed validation: %v", err)
+	}
+
+	c.Metrics["ipmi_fan_speed_rpm"] = MetricConfig{Unit: "rpm"}
+	if err := c.Validate(); err == nil {
+		t.Error("a unit override passed validation")
+	}
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"crypto/tls"
+	"sort"
+	"strings"
+
+	"github.com/mllnd/sherlock/internal/logging"
+)
+
+// LogEffective logs the effective configuration at startup so that operators can confirm it
+// from the logs. Credentials are never logged, only whether they are the built-in defaults.
+func (c *Config) LogEffective(logger *logging.Logger) {
+	logger.Info("effective configuration",
+		"collectors", c.effectiveCollectors(),
+		"targets", len(c.Targets),
+		"groups", len(c.Groups),
+		"timeout", c.Timeout,
+		"connect_timeout", c.ConnectTimeout,
+		"tls_min_version", tls.VersionName(c.TLSMinVersionFor("")),
+		"tls_cipher_suites", c.TLSCipherSuites,
+		"insecure", c.RedfishInsecure,
+		"default_credentials", c.DefaultCredentials(),
+		"restrict_targets", c.RestrictTargets,
+		"all_chassis", c.AllChassis,
+		"unsupported_collectors", c.UnsupportedCollectors,
+		"max_series", c.MaxSeries,
+		"changes_only", c.ChangesOnly,
+		"labels", c.Labels.String(),
+		"admin_endpoints", c.AdminEnabled(),
+		"strict", c.Strict,
+	)
+}
+
+// effectiveCollectors describes the enabled collectors: "all", or the collectors of each group
+// that limits them, e.g. "all; edge: bmc,system"
+func (c *Config) effectiveCollectors() string {
+	parts := []string{"all"}
+	for _, group := range c.Groups {
+		if len(group.Collectors) == 0 {
+			continue
+		}
+		collectors := append([]string(nil), group.Collectors...)
+		sort.Strings(collectors)
+		parts = append(parts, group.Name+": "+strings.Join(collectors, ","))
+	}
+	return strings.Join(parts, "; ")
+}
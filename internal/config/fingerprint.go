@@ -0,0 +1,51 @@
+package config
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sort"
+)
+
+// targetSettings are the resolved settings of a target that make up its fingerprint. Credentials
+// are left out so that the fingerprint can be exposed.
+type targetSettings struct {
+	Collectors    []string `json:"collectors"`
+	Timeout       string   `json:"timeout"`
+	Labels        Labels   `json:"labels"`
+	Group         string   `json:"group"`
+	BasePath      string   `json:"base_path"`
+	TLSMinVersion uint16   `json:"tls_min_version"`
+	Aggregator    bool     `json:"aggregator"`
+	IPMIFallback  bool     `json:"ipmi_fallback"`
+	PinAddress    bool     `json:"pin_address"`
+	ODataVersion  string   `json:"odata_version"`
+	Accept        string   `json:"accept"`
+}
+
+// Fingerprint returns a stable hash of the resolved settings of the given host, which changes
+// whenever any of them does. It fits into a float64 without loss.
+func (c *Config) Fingerprint(host string) uint32 {
+	target, _ := c.Target(host)
+	settings := targetSettings{
+		Timeout:       c.TimeoutFor(host).String(),
+		Labels:        c.LabelsFor(host),
+		Group:         target.Group,
+		BasePath:      target.BasePath,
+		TLSMinVersion: c.TLSMinVersionFor(host),
+		Aggregator:    target.Aggregator,
+		IPMIFallback:  target.IPMIFallback,
+		PinAddress:    target.PinAddress,
+		ODataVersion:  target.ODataVersion,
+		Accept:        target.Accept,
+	}
+	for name := range c.CollectorsFor(host) {
+		settings.Collectors = append(settings.Collectors, name)
+	}
+	sort.Strings(settings.Collectors)
+
+	// Maps are encoded with sorted keys, so equal settings always encode the same
+	data, _ := json.Marshal(settings)
+	hash := fnv.New32a()
+	hash.Write(data)
+	return hash.Sum32()
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TargetGroup holds the settings shared by the member hosts of a group in the config file
+type TargetGroup struct {
+	Name     string   `yaml:"name"`
+	Hosts    []string `yaml:"hosts"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	Timeout  string   `yaml:"timeout"`
+
+	// Collectors limits the members to the named collectors (empty = all)
+	Collectors []string `yaml:"collectors"`
+
+	// Labels are added to every metric of the members
+	Labels map[string]string `yaml:"labels"`
+}
+
+// collectorNames are the names of the Redfish collectors that can be selected per group
+var collectorNames = map[string]bool{
+	"bmc":       true,
+	"cable":     true,
+	"chassis":   true,
+	"event":     true,
+	"fan":       true,
+	"memory":    true,
+	"power":     true,
+	"sensor":    true,
+	"storage":   true,
+	"system":    true,
+	"telemetry": true,
+}
+
+// addGroupMembers adds the hosts listed in the groups as targets of their group
+func (c *Config) addGroupMembers() {
+	for _, group := range c.Groups {
+		for _, host := range group.Hosts {
+			c.Targets = append(c.Targets, TargetConfig{Host: host, Group: group.Name})
+		}
+	}
+}
+
+// Group returns the group the given host belongs to, if any
+func (c *Config) Group(host string) (TargetGroup, bool) {
+	target, ok := c.Target(host)
+	if !ok || target.Group == "" {
+		return TargetGroup{}, false
+	}
+	for _, group := range c.Groups {
+		if group.Name == target.Group {
+			return group, true
+		}
+	}
+	return TargetGroup{}, false
+}
+
+// CredentialsFor returns the Redfish credentials for the given host, falling back to the global ones
+func (c *Config) CredentialsFor(host string) (string, string) {
+	username, password := c.RedfishUsername, c.RedfishPassword
+	if group, ok := c.Group(host); ok {
+		if group.Username != "" {
+			username = group.Username
+		}
+		if group.Password != "" {
+			password = group.Password
+		}
+	}
+	return username, password
+}
+
+// CollectorsFor returns the collectors enabled for the given host, or nil for all of them
+func (c *Config) CollectorsFor(host string) map[string]bool {
+	group, ok := c.Group(host)
+	if !ok || len(group.Collectors) == 0 {
+		return nil
+	}
+
+	enabled := make(map[string]bool, len(group.Collectors))
+	for _, name := range group.Collectors {
+		enabled[name] = true
+	}
+	return enabled
+}
+
+// LabelsFor returns the static labels of the given host's metrics, including those of its group
+func (c *Config) LabelsFor(host string) Labels {
+	group, ok := c.Group(host)
+	if !ok || len(group.Labels) == 0 {
+		return c.Labels
+	}
+
+	labels := make(Labels, len(c.Labels)+len(group.Labels))
+	for key, value := range c.Labels {
+		labels[key] = value
+	}
+	for key, value := range group.Labels {
+		labels[key] = value
+	}
+	return labels
+}
+
+// validateGroups checks the groups and the group references and uniqueness of the targets
+func (c *Config) validateGroups() error {
+	groups := make(map[string]bool, len(c.Groups))
+	for _, group := range c.Groups {
+		if group.Name == "" {
+			return fmt.Errorf("config file group is missing a name")
+		}
+		if groups[group.Name] {
+			return fmt.Errorf("group %q is defined more than once in config file", group.Name)
+		}
+		groups[group.Name] = true
+
+		if group.Timeout != "" {
+			if d, err := time.ParseDuration(group.Timeout); err != nil || d <= 0 {
+				return fmt.Errorf("invalid timeout %q for group %s", group.Timeout, group.Name)
+			}
+		}
+		for _, name := range group.Collectors {
+			if !collectorNames[name] {
+				return fmt.Errorf("invalid collector %q for group %s", name, group.Name)
+			}
+		}
+		for key := range group.Labels {
+			if !labelNameRE.MatchString(key) || strings.HasPrefix(key, "__") {
+				return fmt.Errorf("invalid label name %q for group %s", key, group.Name)
+			}
+			if _, ok := c.Labels[key]; ok {
+				return fmt.Errorf("label %q of group %s collides with a static label", key, group.Name)
+			}
+			if key == "subordinate" {
+				return fmt.Errorf("label \"subordinate\" of group %s collides with the aggregator label", group.Name)
+			}
+		}
+	}
+
+	hosts := make(map[string]bool, len(c.Targets))
+	for _, target := range c.Targets {
+		if hosts[target.Host] {
+			return fmt.Errorf("target %s is listed more than once in config file", target.Host)
+		}
+		hosts[target.Host] = true
+
+		if target.Group != "" && !groups[target.Group] {
+			return fmt.Errorf("target %s references unknown group %q", target.Host, target.Group)
+		}
+	}
+	return nil
+}
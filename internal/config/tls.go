@@ -0,0 +1,67 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// tlsVersions maps the accepted TLS version settings to their protocol versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion converts a TLS version setting such as "1.2" to its protocol version
+func parseTLSVersion(version string) (uint16, error) {
+	if v, ok := tlsVersions[version]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q (expected 1.0, 1.1, 1.2 or 1.3)", version)
+}
+
+// parseCipherSuites converts a comma-separated list of cipher suite names to their IDs. Insecure
+// suites are accepted since legacy BMCs may not support anything else.
+func parseCipherSuites(names string) ([]uint16, error) {
+	if names == "" {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// TLSMinVersionFor returns the minimum TLS version for the given host, falling back to the global default
+func (c *Config) TLSMinVersionFor(host string) uint16 {
+	version := c.TLSMinVersion
+	if target, ok := c.Target(host); ok && target.TLSMinVersion != "" {
+		version = target.TLSMinVersion
+	}
+
+	v, err := parseTLSVersion(version)
+	if err != nil {
+		return tls.VersionTLS12
+	}
+	return v
+}
+
+// TLSCipherSuiteIDs returns the allowed cipher suites, or nil for the Go defaults
+func (c *Config) TLSCipherSuiteIDs() []uint16 {
+	ids, _ := parseCipherSuites(c.TLSCipherSuites)
+	return ids
+}
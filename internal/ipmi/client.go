@@ -0,0 +1,108 @@
+package ipmi
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client reads sensor data from a BMC over IPMI-over-LAN using the ipmitool command rather than a
+// Go IPMI library, so the fallback needs the binary installed on the exporter host
+type Client struct {
+	Host     string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// Sensor is a single reading from the BMC's sensor data repository
+type Sensor struct {
+	Name   string
+	Value  float64
+	Unit   string
+	Status string
+}
+
+// Sensors returns all sensors with a reading
+func (c *Client) Sensors(ctx context.Context) ([]Sensor, error) {
+	output, err := c.run(ctx, "-c", "sdr", "list", "full")
+	if err != nil {
+		return nil, err
+	}
+	return parseSensors(output)
+}
+
+// parseSensors parses the CSV output of ipmitool sdr list, skipping sensors without a reading
+func parseSensors(output []byte) ([]Sensor, error) {
+	records, err := csv.NewReader(bytes.NewReader(output)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse sensor list: %v", err)
+	}
+
+	var sensors []Sensor
+	for _, record := range records {
+		if len(record) < 4 {
+			continue
+		}
+
+		// Sensors without a reading report "na"
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			continue
+		}
+
+		sensors = append(sensors, Sensor{
+			Name:   strings.TrimSpace(record[0]),
+			Value:  value,
+			Unit:   strings.TrimSpace(record[2]),
+			Status: strings.TrimSpace(record[3]),
+		})
+	}
+	return sensors, nil
+}
+
+// PowerOn reports whether the chassis is powered on
+func (c *Client) PowerOn(ctx context.Context) (bool, error) {
+	output, err := c.run(ctx, "chassis", "power", "status")
+	if err != nil {
+		return false, err
+	}
+	return parsePowerOn(output), nil
+}
+
+// parsePowerOn parses the output of ipmitool chassis power status
+func parsePowerOn(output []byte) bool {
+	return strings.HasSuffix(strings.TrimSpace(string(output)), "on")
+}
+
+// runCommand runs a command and returns its standard output, replaceable to fake ipmitool
+var runCommand = func(cmd *exec.Cmd) ([]byte, error) {
+	return cmd.Output()
+}
+
+// run executes ipmitool against the BMC, passing the password through the environment
+func (c *Client) run(ctx context.Context, args ...string) ([]byte, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "ipmitool", append([]string{"-I", "lanplus", "-H", c.Host, "-U", c.Username, "-E"}, args...)...)
+	cmd.Env = append(os.Environ(), "IPMI_PASSWORD="+c.Password)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := runCommand(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("ipmitool %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
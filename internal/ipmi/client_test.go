@@ -0,0 +1,80 @@
+package ipmi
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// sdrList is the output of ipmitool -c sdr list full on a Supermicro BMC
+const sdrList = `CPU Temp,45,degrees C,ok
+System Temp,31,degrees C,ok
+FAN1,4200,RPM,ok
+FAN2,na,RPM,ns
+12V,12.19,Volts,ok
+PS1 Status,0x01,discrete,ok
+`
+
+// fakeIPMITool replaces ipmitool with canned output by subcommand, recording the arguments
+func fakeIPMITool(t *testing.T, outputs map[string]string) *[]string {
+	t.Helper()
+
+	var args []string
+	original := runCommand
+	t.Cleanup(func() { runCommand = original })
+	runCommand = func(cmd *exec.Cmd) ([]byte, error) {
+		args = cmd.Args
+		for subcommand, output := range outputs {
+			if strings.Contains(strings.Join(cmd.Args, " "), subcommand) {
+				return []byte(output), nil
+			}
+		}
+		t.Fatalf("unexpected command %v", cmd.Args)
+		return nil, nil
+	}
+	return &args
+}
+
+func TestSensors(t *testing.T) {
+	fakeIPMITool(t, map[string]string{"sdr list": sdrList})
+	client := &Client{Host: "10.0.0.1", Username: "admin", Password: "secret"}
+
+	sensors, err := client.Sensors(context.Background())
+	if err != nil {
+		t.Fatalf("Sensors failed: %v", err)
+	}
+
+	want := []Sensor{
+		{Name: "CPU Temp", Value: 45, Unit: "degrees C", Status: "ok"},
+		{Name: "System Temp", Value: 31, Unit: "degrees C", Status: "ok"},
+		{Name: "FAN1", Value: 4200, Unit: "RPM", Status: "ok"},
+		{Name: "12V", Value: 12.19, Unit: "Volts", Status: "ok"},
+	}
+	if len(sensors) != len(want) {
+		t.Fatalf("got %d sensors, want %d: %+v", len(sensors), len(want), sensors)
+	}
+	for i := range want {
+		if sensors[i] != want[i] {
+			t.Errorf("sensor %d: got %+v, want %+v", i, sensors[i], want[i])
+		}
+	}
+}
+
+func TestPowerOn(t *testing.T) {
+	for output, want := range map[string]bool{
+		"Chassis Power is on\n":  true,
+		"Chassis Power is off\n": false,
+	} {
+		args := fakeIPMITool(t, map[string]string{"chassis power status": output})
+		client := &Client{Host: "10.0.0.1", Username: "admin", Password: "secret"}
+
+		on, err := client.PowerOn(context.Background())
+		if err != nil || on != want {
+			t.Errorf("%q: got %v, error %v, want %v", output, on, err, want)
+		}
+		if strings.Contains(strings.Join(*args, " "), "secret") {
+			t.Error("the password was passed on the command line")
+		}
+	}
+}
@@ -13,8 +13,9 @@ type Logger struct {
 	*zap.SugaredLogger
 }
 
-// New creates a new Logger instance configured for production use
-func New() *Logger {
+// New creates a new Logger instance configured for production use. The level is taken from
+// LOG_LEVEL, or from LOG_LEVEL_<NAME> for a named logger if set.
+func New(name string) *Logger {
 	config := zap.NewProductionEncoderConfig()
 	config.TimeKey = "ts"
 	config.LevelKey = "level"
@@ -29,9 +30,15 @@ func New() *Logger {
 	config.NameKey = ""
 	config.FunctionKey = ""
 
-	// Determine log level from environment
+	// Determine log level from environment, preferring the override for this logger
+	setting := os.Getenv("LOG_LEVEL")
+	if name != "" {
+		if override, ok := os.LookupEnv("LOG_LEVEL_" + strings.ToUpper(name)); ok {
+			setting = override
+		}
+	}
 	level := zap.InfoLevel
-	if strings.ToLower(os.Getenv("LOG_LEVEL")) == "debug" {
+	if strings.ToLower(setting) == "debug" {
 		level = zap.DebugLevel
 	}
 
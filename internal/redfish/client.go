@@ -1,21 +1,42 @@
 package redfish
 
 import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/common"
 	"github.com/stmcginnis/gofish/redfish"
 )
 
-// Client wraps the gofish API client with additional functionality
+// Client wraps the gofish API client with additional functionality. Its requests go through
+// service; the embedded APIClient and Service are those of the gofish session, if connected to one.
 type Client struct {
 	*gofish.APIClient
 	Service *gofish.Service
+	service redfishService
 	config  Config
 	mutex   sync.Mutex
+
+	// connectedAt is when the current Redfish session was established
+	connectedAt time.Time
+
+	// openBMC caches whether the BMC runs OpenBMC, once detected
+	openBMC *bool
+
+	// root caches the properties of the service root, once read
+	root map[string]json.RawMessage
 }
 
 // Config holds the configuration for the Redfish client
@@ -24,6 +45,40 @@ type Config struct {
 	Username string
 	Password string
 	Insecure bool
+	Timeout  time.Duration
+	Retry    RetryPolicy
+
+	// ConnectTimeout limits how long establishing a TCP connection may take, so that unreachable
+	// BMCs fail fast while slow ones get the full Timeout (default: 5s)
+	ConnectTimeout time.Duration
+
+	// MaxRedirects limits how many redirects are followed (default: 10)
+	MaxRedirects int
+
+	// ReconnectJitter is the maximum random delay before re-establishing an expired session
+	ReconnectJitter time.Duration
+
+	// MaxConcurrentRequests limits the concurrent requests to the BMC (default: 1)
+	MaxConcurrentRequests int64
+
+	// MinTLSVersion is the minimum TLS version accepted (default: TLS 1.2)
+	MinTLSVersion uint16
+
+	// CipherSuites limits the TLS 1.0-1.2 cipher suites offered (default: the Go defaults)
+	CipherSuites []uint16
+
+	// BasePath is the path the Redfish API is served under (default: /redfish/v1)
+	BasePath string
+
+	// PinAddress resolves the host once per session and sends all its requests to that address,
+	// keeping the session on one backend behind a load-balanced BMC name
+	PinAddress bool
+
+	// ODataVersion, when set, is sent as the OData-Version header of every request
+	ODataVersion string
+
+	// Accept, when set, replaces the Accept header of every request (default: application/json)
+	Accept string
 }
 
 // NewConfig creates a new Config with values from environment or defaults
@@ -46,37 +101,146 @@ func NewClient(config Config) (*Client, error) {
 	return client, nil
 }
 
+// defaultConnectTimeout is the connect timeout used when none is configured
+const defaultConnectTimeout = 5 * time.Second
+
+// newHTTPClient builds the HTTP client used to talk to the Redfish API. If address is set,
+// connections go to that IP instead of resolving the host, which is still used for TLS.
+func newHTTPClient(config Config, address string) *http.Client {
+	defaultTransport := http.DefaultTransport.(*http.Transport)
+	connectTimeout := config.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = defaultConnectTimeout
+	}
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	dialContext := dialer.DialContext
+	if address != "" {
+		// Only connections to the target are pinned, not those to a proxy or another host
+		// the BMC redirects to
+		var target string
+		if u, err := url.Parse(config.Host); err == nil {
+			target = u.Hostname()
+		}
+		dialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			if host != target {
+				return dialer.DialContext(ctx, network, addr)
+			}
+			return dialer.DialContext(ctx, network, net.JoinHostPort(address, port))
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy:                 defaultTransport.Proxy,
+		DialContext:           dialContext,
+		MaxIdleConns:          defaultTransport.MaxIdleConns,
+		IdleConnTimeout:       defaultTransport.IdleConnTimeout,
+		ExpectContinueTimeout: defaultTransport.ExpectContinueTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: config.Insecure,
+			MinVersion:         config.MinTLSVersion,
+			CipherSuites:       config.CipherSuites,
+		},
+	}
+
+	var next http.RoundTripper = transport
+	if basePath := strings.TrimSuffix(config.BasePath, "/"); basePath != "" && basePath != standardBasePath {
+		next = &basePathTransport{next: transport, basePath: basePath}
+	}
+	if config.ODataVersion != "" || config.Accept != "" {
+		next = &headerTransport{next: next, odataVersion: config.ODataVersion, accept: config.Accept}
+	}
+
+	return &http.Client{
+		Transport: &retryTransport{
+			next:   next,
+			policy: config.Retry,
+			target: targetName(config.Host),
+		},
+		Timeout:       config.Timeout,
+		CheckRedirect: checkRedirect(config.MaxRedirects),
+	}
+}
+
+// targetName returns the target of an endpoint URL, as used in the exporter's metric labels
+func targetName(endpoint string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(endpoint, "https://"), "http://")
+}
+
+// pinnedAddress resolves the host of the endpoint to the address a session is pinned to
+func pinnedAddress(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+
+	addresses, err := net.LookupHost(u.Hostname())
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", u.Hostname(), err)
+	}
+	return addresses[0], nil
+}
+
 // connect establishes a new connection to the Redfish API
 func connect(config Config) (*Client, error) {
+	var address string
+	if config.PinAddress {
+		var err error
+		if address, err = pinnedAddress(config.Host); err != nil {
+			return nil, err
+		}
+	}
+
 	goConfig := gofish.ClientConfig{
-		Endpoint: config.Host,
-		Username: config.Username,
-		Password: config.Password,
-		Insecure: config.Insecure,
+		Endpoint:   config.Host,
+		Username:   config.Username,
+		Password:   config.Password,
+		Insecure:   config.Insecure,
+		HTTPClient: newHTTPClient(config, address),
+
+		MaxConcurrentRequests: config.MaxConcurrentRequests,
 	}
 
-	apiClient, err := gofish.Connect(goConfig)
+	service, err := connectService(goConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Redfish API: %v", err)
+		var redirectErr *RedirectError
+		if errors.As(err, &redirectErr) {
+			return nil, redirectErr
+		}
+		return nil, fmt.Errorf("failed to connect to Redfish API: %w", err)
 	}
 
 	client := &Client{
-		APIClient: apiClient,
-		Service:   apiClient.Service,
-		config:    config,
+		service:     service,
+		config:      config,
+		connectedAt: time.Now(),
+	}
+	if session, ok := service.(gofishService); ok {
+		client.APIClient = session.APIClient
+		client.Service = session.Service
 	}
 
 	return client, nil
 }
 
-// reconnect attempts to establish a new connection
+// reconnect attempts to establish a new connection. The caller must hold the mutex.
+// Reconnections are delayed by a random jitter and limited across all targets.
 func (c *Client) reconnect() error {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	time.Sleep(reconnectDelay(c.config.ReconnectJitter))
+
+	reconnectSlots <- struct{}{}
+	defer func() { <-reconnectSlots }()
 
 	// Close existing connection if any
-	if c.APIClient != nil {
-		c.Close()
+	if c.service != nil {
+		c.service.Logout()
 	}
 
 	// Create new connection
@@ -88,16 +252,42 @@ func (c *Client) reconnect() error {
 	// Update client with new connection
 	c.APIClient = newClient.APIClient
 	c.Service = newClient.Service
+	c.service = newClient.service
+	c.connectedAt = newClient.connectedAt
 
 	return nil
 }
 
+// ConnectedAt returns when the current Redfish session was established
+func (c *Client) ConnectedAt() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.connectedAt
+}
+
 // GetChassis returns all chassis from the Redfish API
 func (c *Client) GetChassis() ([]*redfish.Chassis, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	return c.Service.Chassis()
+	return c.service.Chassis()
+}
+
+// GetSystems returns all computer systems from the Redfish API
+func (c *Client) GetSystems() ([]*redfish.ComputerSystem, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.service.Systems()
+}
+
+// GetManagers returns all managers from the Redfish API
+func (c *Client) GetManagers() ([]*redfish.Manager, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.service.Managers()
 }
 
 // GetMainChassis returns the main chassis (ID "1") from the Redfish API
@@ -105,17 +295,17 @@ func (c *Client) GetMainChassis() (*redfish.Chassis, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	chassis, err := c.Service.Chassis()
+	chassis, err := c.service.Chassis()
 	if err != nil {
 		// Check if we got a partial response
-		if strings.Contains(err.Error(), "failed to retrieve some items") && len(chassis) > 0 {
+		if c.partialChassis(err, chassis) {
 			// Continue with the chassis we got
 		} else if isAuthError(err) {
 			// Try to reconnect and retry once
 			if reconnectErr := c.reconnect(); reconnectErr != nil {
 				return nil, fmt.Errorf("failed to reconnect: %v (original error: %v)", reconnectErr, err)
 			}
-			chassis, err = c.Service.Chassis()
+			chassis, err = c.service.Chassis()
 			if err != nil {
 				return nil, err
 			}
@@ -138,6 +328,37 @@ func (c *Client) GetMainChassis() (*redfish.Chassis, error) {
 	return nil, fmt.Errorf("main chassis (ID 1) not found")
 }
 
+// ForEachChassis calls fn for every chassis, running at most workers calls concurrently
+func (c *Client) ForEachChassis(workers int, fn func(chassis *redfish.Chassis)) error {
+	chassis, err := c.GetChassis()
+	if err != nil && len(chassis) == 0 {
+		return err
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for _, ch := range chassis {
+		if ch == nil {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(ch *redfish.Chassis) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(ch)
+		}(ch)
+	}
+	wg.Wait()
+
+	return nil
+}
+
 // filterNumericChassis returns only chassis with numeric IDs
 func filterNumericChassis(chassis []*redfish.Chassis) []*redfish.Chassis {
 	var result []*redfish.Chassis
@@ -159,17 +380,17 @@ func (c *Client) GetChassisWithID(id string) (*redfish.Chassis, error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	chassis, err := c.Service.Chassis()
+	chassis, err := c.service.Chassis()
 	if err != nil {
 		// Check if we got a partial response
-		if strings.Contains(err.Error(), "failed to retrieve some items") && len(chassis) > 0 {
+		if c.partialChassis(err, chassis) {
 			// Continue with the chassis we got
 		} else if isAuthError(err) {
 			// Try to reconnect and retry once
 			if reconnectErr := c.reconnect(); reconnectErr != nil {
 				return nil, fmt.Errorf("failed to reconnect: %v (original error: %v)", reconnectErr, err)
 			}
-			chassis, err = c.Service.Chassis()
+			chassis, err = c.service.Chassis()
 			if err != nil {
 				return nil, err
 			}
@@ -192,6 +413,192 @@ func (c *Client) GetChassisWithID(id string) (*redfish.Chassis, error) {
 	return nil, fmt.Errorf("chassis with ID %s not found", id)
 }
 
+// Subordinates returns the IDs of the chassis exposed by a Redfish aggregator. The boolean
+// is false when the service has no AggregationService and should be treated as a single system.
+func (c *Client) Subordinates() ([]string, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	aggregation, err := c.service.AggregationService()
+	if err != nil {
+		return nil, false, err
+	}
+	if aggregation == nil {
+		return nil, false, nil
+	}
+
+	chassis, err := c.service.Chassis()
+	if err != nil {
+		return nil, true, err
+	}
+
+	ids := make([]string, 0, len(chassis))
+	for _, ch := range chassis {
+		if ch != nil && ch.ID != "" {
+			ids = append(ids, ch.ID)
+		}
+	}
+	return ids, true, nil
+}
+
+// Ping checks the connection with a GET of the service root and reconnects if it fails.
+// It returns how long the check took.
+func (c *Client) Ping() (time.Duration, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	start := time.Now()
+	resp, err := c.service.Get("/redfish/v1/")
+	if err == nil {
+		resp.Body.Close()
+		return time.Since(start), nil
+	}
+
+	if reconnectErr := c.reconnect(); reconnectErr != nil {
+		return time.Since(start), fmt.Errorf("failed to reconnect: %v (original error: %v)", reconnectErr, err)
+	}
+	return time.Since(start), nil
+}
+
+// IsServicePath reports whether a path is the Redfish service root or below it
+func IsServicePath(path string) bool {
+	return path == "/redfish/v1" || strings.HasPrefix(path, "/redfish/v1/")
+}
+
+// GetRaw performs a GET against the given Redfish path and returns the raw response body
+func (c *Client) GetRaw(path string) ([]byte, error) {
+	if !IsServicePath(path) {
+		return nil, fmt.Errorf("path must be below /redfish/v1")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	resp, err := c.service.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// IsOpenBMC reports whether the BMC runs OpenBMC, detected from the manufacturer and model of
+// its managers. The result is cached for the lifetime of the client.
+func (c *Client) IsOpenBMC() (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.openBMC != nil {
+		return *c.openBMC, nil
+	}
+
+	managers, err := c.service.Managers()
+	if err != nil {
+		return false, err
+	}
+
+	openBMC := false
+	for _, manager := range managers {
+		if strings.Contains(strings.ToLower(manager.Manufacturer+" "+manager.Model), "openbmc") {
+			openBMC = true
+			break
+		}
+	}
+	c.openBMC = &openBMC
+
+	return openBMC, nil
+}
+
+// serviceRoot returns the properties of the service root. The service root is read once for the
+// lifetime of the client.
+func (c *Client) serviceRoot() (map[string]json.RawMessage, error) {
+	c.mutex.Lock()
+	root := c.root
+	c.mutex.Unlock()
+	if root != nil {
+		return root, nil
+	}
+
+	body, err := c.GetRaw("/redfish/v1/")
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(body, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse service root: %w", err)
+	}
+	if root == nil {
+		root = make(map[string]json.RawMessage)
+	}
+
+	c.mutex.Lock()
+	c.root = root
+	c.mutex.Unlock()
+
+	return root, nil
+}
+
+// rootLink returns the link of the given property of the service root, or "" if it has none
+func rootLink(root map[string]json.RawMessage, name string) string {
+	var link common.Link
+	if err := json.Unmarshal(root[name], &link); err != nil {
+		return ""
+	}
+	return link.String()
+}
+
+// Capabilities returns the names of the resources and collections linked from the service root,
+// e.g. Chassis, Systems or Managers. The result is cached for the lifetime of the client.
+func (c *Client) Capabilities() (map[string]bool, error) {
+	root, err := c.serviceRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities := make(map[string]bool)
+	for name := range root {
+		if rootLink(root, name) != "" {
+			capabilities[name] = true
+		}
+	}
+	return capabilities, nil
+}
+
+// SupportsTopSkip reports whether the service supports the $top and $skip query parameters for
+// reading part of a collection
+func (c *Client) SupportsTopSkip() bool {
+	root, err := c.serviceRoot()
+	if err != nil {
+		return false
+	}
+
+	var features struct {
+		TopSkipQuery bool
+	}
+	if err := json.Unmarshal(root["ProtocolFeaturesSupported"], &features); err != nil {
+		return false
+	}
+	return features.TopSkipQuery
+}
+
+// PowerEquipment returns the power equipment (e.g. rack PDUs) of the service, or nil if the
+// service root doesn't link any
+func (c *Client) PowerEquipment() (*redfish.PowerEquipment, error) {
+	root, err := c.serviceRoot()
+	if err != nil {
+		return nil, err
+	}
+	link := rootLink(root, "PowerEquipment")
+	if link == "" {
+		return nil, nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.service.PowerEquipment(link)
+}
+
 // isAuthError checks if the error is an authentication error
 func isAuthError(err error) bool {
 	if err == nil {
@@ -208,8 +615,8 @@ func isAuthError(err error) bool {
 func (c *Client) Close() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	if c.APIClient != nil {
-		c.Logout()
+	if c.service != nil {
+		c.service.Logout()
 	}
 }
 
@@ -0,0 +1,310 @@
+package redfish
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/common"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+// fakeService is a redfishService serving canned resources instead of a BMC
+type fakeService struct {
+	root        string
+	getErr      error
+	chassis     []*redfish.Chassis
+	chassisErr  error
+	managers    []*redfish.Manager
+	aggregation *redfish.AggregationService
+
+	getCalls      int
+	chassisCalls  int
+	managersCalls int
+	loggedOut     bool
+}
+
+func (s *fakeService) Get(path string) (*http.Response, error) {
+	s.getCalls++
+	if s.getErr != nil {
+		return nil, s.getErr
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(s.root))}, nil
+}
+
+func (s *fakeService) Chassis() ([]*redfish.Chassis, error) {
+	s.chassisCalls++
+	return s.chassis, s.chassisErr
+}
+
+func (s *fakeService) Systems() ([]*redfish.ComputerSystem, error) {
+	return nil, nil
+}
+
+func (s *fakeService) Managers() ([]*redfish.Manager, error) {
+	s.managersCalls++
+	return s.managers, nil
+}
+
+func (s *fakeService) AggregationService() (*redfish.AggregationService, error) {
+	return s.aggregation, nil
+}
+
+func (s *fakeService) PowerEquipment(link string) (*redfish.PowerEquipment, error) {
+	return &redfish.PowerEquipment{Entity: common.Entity{ODataID: link}}, nil
+}
+
+func (s *fakeService) Logout() {
+	s.loggedOut = true
+}
+
+// newFakeClient returns a client talking to the given fake service, with every reconnection
+// connecting to the next one
+func newFakeClient(t *testing.T, service *fakeService, next ...*fakeService) *Client {
+	t.Helper()
+
+	original := connectService
+	t.Cleanup(func() { connectService = original })
+	connectService = func(gofish.ClientConfig) (redfishService, error) {
+		if len(next) == 0 {
+			return nil, errors.New("connection refused")
+		}
+		service := next[0]
+		next = next[1:]
+		return service, nil
+	}
+
+	return &Client{service: service, config: Config{Host: "https://bmc.example"}}
+}
+
+// chassisWithIDs returns chassis with the given IDs
+func chassisWithIDs(ids ...string) []*redfish.Chassis {
+	chassis := make([]*redfish.Chassis, 0, len(ids))
+	for _, id := range ids {
+		chassis = append(chassis, &redfish.Chassis{Entity: common.Entity{ID: id}})
+	}
+	return chassis
+}
+
+func TestGetMainChassis(t *testing.T) {
+	client := newFakeClient(t, &fakeService{chassis: chassisWithIDs("Blade1", "1")})
+
+	chassis, err := client.GetMainChassis()
+	if err != nil {
+		t.Fatalf("GetMainChassis failed: %v", err)
+	}
+	if chassis.ID != "1" {
+		t.Errorf("got chassis %q, want 1", chassis.ID)
+	}
+}
+
+func TestGetMainChassisNotFound(t *testing.T) {
+	for name, service := range map[string]*fakeService{
+		"empty":   {},
+		"missing": {chassis: chassisWithIDs("Blade1")},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := newFakeClient(t, service)
+			if _, err := client.GetMainChassis(); err == nil {
+				t.Error("GetMainChassis succeeded without a main chassis")
+			}
+		})
+	}
+}
+
+func TestGetMainChassisReconnectsOnAuthError(t *testing.T) {
+	expired := &fakeService{chassisErr: errors.New("401: session expired")}
+	renewed := &fakeService{chassis: chassisWithIDs("1")}
+	client := newFakeClient(t, expired, renewed)
+
+	if _, err := client.GetMainChassis(); err != nil {
+		t.Fatalf("GetMainChassis failed: %v", err)
+	}
+	if !expired.loggedOut {
+		t.Error("the expired session wasn't logged out")
+	}
+	if renewed.chassisCalls != 1 {
+		t.Errorf("the renewed session was asked for the chassis %d times, want 1", renewed.chassisCalls)
+	}
+}
+
+func TestGetMainChassisReconnectFailure(t *testing.T) {
+	client := newFakeClient(t, &fakeService{chassisErr: errors.New("401 Unauthorized")})
+
+	_, err := client.GetMainChassis()
+	if err == nil || !strings.Contains(err.Error(), "failed to reconnect") {
+		t.Errorf("got error %v, want a reconnection failure", err)
+	}
+}
+
+func TestGetMainChassisDoesNotReconnectOnOtherErrors(t *testing.T) {
+	service := &fakeService{chassisErr: errors.New("500 Internal Server Error")}
+	client := newFakeClient(t, service, &fakeService{chassis: chassisWithIDs("1")})
+
+	if _, err := client.GetMainChassis(); err == nil {
+		t.Fatal("GetMainChassis succeeded on a server error")
+	}
+	if service.loggedOut {
+		t.Error("the session was renewed on a server error")
+	}
+}
+
+func TestGetMainChassisWithPartialCollection(t *testing.T) {
+	collectionErr := common.NewCollectionError()
+	collectionErr.Failures["/redfish/v1/Chassis/Blade2"] = errors.New("timeout")
+	client := newFakeClient(t, &fakeService{chassis: chassisWithIDs("1"), chassisErr: collectionErr})
+
+	if _, err := client.GetMainChassis(); err != nil {
+		t.Errorf("GetMainChassis failed on a partial collection: %v", err)
+	}
+}
+
+func TestSubordinates(t *testing.T) {
+	client := newFakeClient(t, &fakeService{})
+	if _, aggregator, err := client.Subordinates(); err != nil || aggregator {
+		t.Errorf("got aggregator %v, error %v for a service without aggregation", aggregator, err)
+	}
+
+	client = newFakeClient(t, &fakeService{
+		aggregation: &redfish.AggregationService{},
+		chassis:     chassisWithIDs("Node1", "", "Node2"),
+	})
+	ids, aggregator, err := client.Subordinates()
+	if err != nil || !aggregator {
+		t.Fatalf("got aggregator %v, error %v for an aggregator", aggregator, err)
+	}
+	if strings.Join(ids, ",") != "Node1,Node2" {
+		t.Errorf("got subordinates %v, want Node1 and Node2", ids)
+	}
+}
+
+func TestPingReconnectsOnFailure(t *testing.T) {
+	failing := &fakeService{getErr: errors.New("connection reset")}
+	client := newFakeClient(t, failing, &fakeService{})
+
+	if _, err := client.Ping(); err != nil {
+		t.Fatalf("Ping failed: %v", err)
+	}
+	if !failing.loggedOut {
+		t.Error("the failing session wasn't renewed")
+	}
+}
+
+func TestIsOpenBMCIsCached(t *testing.T) {
+	service := &fakeService{managers: []*redfish.Manager{{Manufacturer: "OpenBMC", Model: "AST2600"}}}
+	client := newFakeClient(t, service)
+
+	for i := 0; i < 2; i++ {
+		openBMC, err := client.IsOpenBMC()
+		if err != nil || !openBMC {
+			t.Fatalf("got OpenBMC %v, error %v", openBMC, err)
+		}
+	}
+	if service.managersCalls != 1 {
+		t.Errorf("the managers were read %d times, want 1", service.managersCalls)
+	}
+}
+
+func TestPowerEquipment(t *testing.T) {
+	client := newFakeClient(t, &fakeService{root: `{"Chassis": {"@odata.id": "/redfish/v1/Chassis"}}`})
+	if equipment, err := client.PowerEquipment(); err != nil || equipment != nil {
+		t.Errorf("got power equipment %v, error %v for a service root without any", equipment, err)
+	}
+
+	client = newFakeClient(t, &fakeService{root: `{"PowerEquipment": {"@odata.id": "/redfish/v1/PowerEquipment"}}`})
+	equipment, err := client.PowerEquipment()
+	if err != nil || equipment == nil || equipment.ODataID != "/redfish/v1/PowerEquipment" {
+		t.Errorf("got power equipment %v, error %v, want the linked one", equipment, err)
+	}
+}
+
+func TestServiceRootIsCached(t *testing.T) {
+	service := &fakeService{root: `{
+		"Chassis": {"@odata.id": "/redfish/v1/Chassis"},
+		"PowerEquipment": {"@odata.id": "/redfish/v1/PowerEquipment"}
+	}`}
+	client := newFakeClient(t, service)
+
+	for i := 0; i < 2; i++ {
+		if equipment, err := client.PowerEquipment(); err != nil || equipment == nil {
+			t.Fatalf("got power equipment %v, error %v", equipment, err)
+		}
+		capabilities, err := client.Capabilities()
+		if err != nil || !capabilities["Chassis"] || !capabilities["PowerEquipment"] {
+			t.Fatalf("got capabilities %v, error %v", capabilities, err)
+		}
+	}
+	if service.getCalls != 1 {
+		t.Errorf("the service root was read %d times, want 1", service.getCalls)
+	}
+}
+
+func TestIsAuthError(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("401: Unauthorized"), true},
+		{errors.New("unauthorized"), true},
+		{errors.New("authentication required"), true},
+		{errors.New("session expired"), true},
+		{errors.New("500: Internal Server Error"), false},
+		{errors.New("connection refused"), false},
+	} {
+		if got := isAuthError(tt.err); got != tt.want {
+			t.Errorf("isAuthError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestPinnedAddressOnlyForTheTarget(t *testing.T) {
+	pinned := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer pinned.Close()
+
+	// Another host, which connections pinned to 127.0.0.1 couldn't reach
+	listener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("can't listen on a second loopback address: %v", err)
+	}
+	other := &httptest.Server{Listener: listener, Config: &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}}
+	other.Start()
+	defer other.Close()
+
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(pinned.URL, "http://"))
+	target := "http://bmc.invalid:" + port
+	client := newHTTPClient(Config{Host: target}, "127.0.0.1")
+
+	for _, url := range []string{target + "/redfish/v1/", other.URL + "/redfish/v1/"} {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Errorf("GET %s failed: %v", url, err)
+			continue
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestIsServicePath(t *testing.T) {
+	for _, tt := range []struct {
+		path string
+		want bool
+	}{
+		{"/redfish/v1", true},
+		{"/redfish/v1/", true},
+		{"/redfish/v1/Chassis/1", true},
+		{"/redfish/v1foo", false},
+		{"/redfish/v10/Chassis", false},
+		{"/redfish", false},
+		{"", false},
+	} {
+		if got := IsServicePath(tt.path); got != tt.want {
+			t.Errorf("IsServicePath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
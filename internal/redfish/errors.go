@@ -0,0 +1,64 @@
+package redfish
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/stmcginnis/gofish/common"
+)
+
+// Error categories reported for failed scrapes
+const (
+	ErrorAuth        = "auth"
+	ErrorTimeout     = "timeout"
+	ErrorUnreachable = "unreachable"
+	ErrorParse       = "parse"
+	ErrorOther       = "other"
+)
+
+// ErrorCategory maps an error to one of a small, fixed set of categories
+func ErrorCategory(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if isAuthError(err) || strings.Contains(err.Error(), "403") {
+		return ErrorAuth
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) ||
+		strings.Contains(err.Error(), "timeout") {
+		return ErrorTimeout
+	}
+
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) ||
+		strings.Contains(err.Error(), "connection refused") || strings.Contains(err.Error(), "no such host") {
+		return ErrorUnreachable
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return ErrorParse
+	}
+
+	return ErrorOther
+}
+
+// IsNotFound reports whether an error is a 404 response from the BMC
+func IsNotFound(err error) bool {
+	var commonErr *common.Error
+	return errors.As(err, &commonErr) && commonErr.HTTPReturnedStatusCode == http.StatusNotFound
+}
+
+// IsUnavailable reports whether an error means the BMC offers no Redfish service at all
+func IsUnavailable(err error) bool {
+	return IsNotFound(err) || ErrorCategory(err) == ErrorUnreachable
+}
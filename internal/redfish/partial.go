@@ -0,0 +1,42 @@
+package redfish
+
+import (
+	"errors"
+
+	"github.com/mllnd/sherlock/internal/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stmcginnis/gofish/common"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+// ChassisRetrievalErrorsTotal counts the chassis members that failed to be retrieved while
+// listing the chassis of each target
+var ChassisRetrievalErrorsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ipmi_chassis_retrieval_errors_total",
+		Help: "Total number of failed retrievals of a chassis member while listing the chassis of the target",
+	},
+	[]string{"target", "chassis"},
+)
+
+var logger = logging.New("redfish")
+
+// partialChassis reports whether err only means that some members of the chassis collection
+// failed to be retrieved while others were, counting and logging each failed member
+func (c *Client) partialChassis(err error, chassis []*redfish.Chassis) bool {
+	var collectionErr *common.CollectionError
+	if !errors.As(err, &collectionErr) || len(chassis) == 0 {
+		return false
+	}
+
+	target := targetName(c.config.Host)
+	for link, memberErr := range collectionErr.Failures {
+		ChassisRetrievalErrorsTotal.WithLabelValues(target, link).Inc()
+		logger.Warn("failed to retrieve chassis member, continuing without it",
+			"target", target,
+			"chassis", link,
+			"error", memberErr,
+		)
+	}
+	return true
+}
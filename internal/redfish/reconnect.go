@@ -0,0 +1,30 @@
+package redfish
+
+import (
+	"math/rand"
+	"time"
+)
+
+// DefaultMaxConcurrentReconnects is the default limit on reconnections in flight across all targets
+const DefaultMaxConcurrentReconnects = 4
+
+// reconnectSlots limits how many clients reconnect at the same time, so that an expired
+// shared auth backend isn't hit by every target at once
+var reconnectSlots = make(chan struct{}, DefaultMaxConcurrentReconnects)
+
+// SetMaxConcurrentReconnects sets the global reconnection limit. It must be called before
+// any client is created.
+func SetMaxConcurrentReconnects(n int) {
+	if n < 1 {
+		n = 1
+	}
+	reconnectSlots = make(chan struct{}, n)
+}
+
+// reconnectDelay returns a random delay in [0, jitter)
+func reconnectDelay(jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(jitter)))
+}
@@ -0,0 +1,53 @@
+package redfish
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// DefaultMaxRedirects is the number of redirects followed when none is configured
+const DefaultMaxRedirects = 10
+
+// RedirectError is returned when the BMC redirects in a loop or more often than allowed
+type RedirectError struct {
+	URL       string
+	Redirects int
+	Loop      bool
+}
+
+func (e *RedirectError) Error() string {
+	if e.Loop {
+		return fmt.Sprintf("redirect loop detected at %s after %d redirects", e.URL, e.Redirects)
+	}
+	return fmt.Sprintf("stopped after %d redirects at %s", e.Redirects, e.URL)
+}
+
+// sessionHeaders are the credentials sent with each request, which net/http only strips from
+// redirects to another host for the standard Authorization header
+var sessionHeaders = []string{"Authorization", "X-Auth-Token"}
+
+// checkRedirect returns a CheckRedirect function that follows at most max redirects
+// and fails with a RedirectError on loops. Redirects to another host don't carry the
+// session credentials.
+func checkRedirect(max int) func(req *http.Request, via []*http.Request) error {
+	if max <= 0 {
+		max = DefaultMaxRedirects
+	}
+
+	return func(req *http.Request, via []*http.Request) error {
+		for _, previous := range via {
+			if previous.URL.String() == req.URL.String() {
+				return &RedirectError{URL: req.URL.String(), Redirects: len(via), Loop: true}
+			}
+		}
+		if len(via) > max {
+			return &RedirectError{URL: req.URL.String(), Redirects: len(via)}
+		}
+		if req.URL.Host != via[0].URL.Host {
+			for _, header := range sessionHeaders {
+				req.Header.Del(header)
+			}
+		}
+		return nil
+	}
+}
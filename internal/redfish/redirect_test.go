@@ -0,0 +1,95 @@
+package redfish
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestRedirectLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+		http.Redirect(w, r, "/redfish/v1/?n="+strconv.Itoa(n+1), http.StatusPermanentRedirect)
+	}))
+	defer server.Close()
+
+	client := newHTTPClient(Config{Host: server.URL, MaxRedirects: 3}, "")
+	resp, err := client.Get(server.URL + "/redfish/v1/")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("endless redirects were followed")
+	}
+
+	var redirectErr *RedirectError
+	if !errors.As(err, &redirectErr) {
+		t.Fatalf("got error %v, want a RedirectError", err)
+	}
+	if redirectErr.Loop || redirectErr.Redirects != 4 {
+		t.Errorf("got %+v, want to stop after 3 redirects", redirectErr)
+	}
+}
+
+func TestRedirectLoop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redfish/v1/" {
+			http.Redirect(w, r, "/redfish/v1", http.StatusPermanentRedirect)
+			return
+		}
+		http.Redirect(w, r, "/redfish/v1/", http.StatusPermanentRedirect)
+	}))
+	defer server.Close()
+
+	client := newHTTPClient(Config{Host: server.URL}, "")
+	_, err := client.Get(server.URL + "/redfish/v1/")
+
+	var redirectErr *RedirectError
+	if !errors.As(err, &redirectErr) || !redirectErr.Loop {
+		t.Errorf("got error %v, want a redirect loop", err)
+	}
+}
+
+func TestRedirectToAnotherHostDropsCredentials(t *testing.T) {
+	var sameHost, otherHost http.Header
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		otherHost = r.Header.Clone()
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redfish/v1/":
+			http.Redirect(w, r, "/redfish/v1/Systems", http.StatusPermanentRedirect)
+		case "/redfish/v1/Systems":
+			sameHost = r.Header.Clone()
+			http.Redirect(w, r, other.URL+"/redfish/v1/Systems", http.StatusPermanentRedirect)
+		}
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/redfish/v1/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Auth-Token", "secret")
+	req.SetBasicAuth("admin", "password")
+
+	resp, err := newHTTPClient(Config{Host: server.URL}, "").Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if sameHost.Get("X-Auth-Token") != "secret" || sameHost.Get("Authorization") == "" {
+		t.Error("a redirect on the same host dropped the credentials")
+	}
+	if otherHost == nil {
+		t.Fatal("the redirect to the other host wasn't followed")
+	}
+	for _, header := range sessionHeaders {
+		if value := otherHost.Get(header); value != "" {
+			t.Errorf("the other host received %s %q", header, value)
+		}
+	}
+}
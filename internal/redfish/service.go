@@ -0,0 +1,73 @@
+package redfish
+
+import (
+	"net/http"
+
+	"github.com/stmcginnis/gofish"
+	"github.com/stmcginnis/gofish/redfish"
+)
+
+// redfishService is the session with the Redfish API the client sends its requests through, so
+// that a fake service can stand in for a BMC. The resources it returns are gofish objects, which
+// fetch their own links through the session they were read with.
+type redfishService interface {
+	// Get performs a GET against a Redfish path
+	Get(path string) (*http.Response, error)
+
+	// Chassis returns all chassis of the service
+	Chassis() ([]*redfish.Chassis, error)
+
+	// Systems returns all computer systems of the service
+	Systems() ([]*redfish.ComputerSystem, error)
+
+	// Managers returns all managers of the service
+	Managers() ([]*redfish.Manager, error)
+
+	// AggregationService returns the aggregation service, or nil if the service has none
+	AggregationService() (*redfish.AggregationService, error)
+
+	// PowerEquipment returns the power equipment linked at the given path
+	PowerEquipment(link string) (*redfish.PowerEquipment, error)
+
+	// Logout ends the Redfish session
+	Logout()
+}
+
+// gofishService implements redfishService with a gofish API client
+type gofishService struct {
+	*gofish.APIClient
+}
+
+// Chassis returns all chassis of the service
+func (s gofishService) Chassis() ([]*redfish.Chassis, error) {
+	return s.Service.Chassis()
+}
+
+// Systems returns all computer systems of the service
+func (s gofishService) Systems() ([]*redfish.ComputerSystem, error) {
+	return s.Service.Systems()
+}
+
+// Managers returns all managers of the service
+func (s gofishService) Managers() ([]*redfish.Manager, error) {
+	return s.Service.Managers()
+}
+
+// AggregationService returns the aggregation service, or nil if the service has none
+func (s gofishService) AggregationService() (*redfish.AggregationService, error) {
+	return s.Service.AggregationService()
+}
+
+// PowerEquipment returns the power equipment linked at the given path
+func (s gofishService) PowerEquipment(link string) (*redfish.PowerEquipment, error) {
+	return redfish.GetPowerEquipment(s.APIClient, link)
+}
+
+// connectService establishes a Redfish session, replaceable to connect to a fake service
+var connectService = func(config gofish.ClientConfig) (redfishService, error) {
+	apiClient, err := gofish.Connect(config)
+	if err != nil {
+		return nil, err
+	}
+	return gofishService{apiClient}, nil
+}
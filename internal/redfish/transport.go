@@ -0,0 +1,127 @@
+package redfish
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RateLimitedTotal counts the HTTP 429 responses received from each target
+var RateLimitedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "sherlock_redfish_rate_limited_total",
+		Help: "Total number of rate limited (HTTP 429) responses received from the target",
+	},
+	[]string{"target"},
+)
+
+// RetryPolicy controls how requests rejected by the BMC are retried
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries for a single request
+	MaxRetries int
+
+	// MaxWait caps how long a Retry-After header can make us wait
+	MaxWait time.Duration
+}
+
+// retryTransport retries requests that the BMC rejected with HTTP 429
+type retryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+	target string
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+
+		RateLimitedTotal.WithLabelValues(t.target).Inc()
+
+		// Give up once retries are exhausted or the body can't be replayed
+		if attempt >= t.policy.MaxRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		wait := retryAfter(resp.Header.Get("Retry-After"), t.policy.MaxWait)
+		resp.Body.Close()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// standardBasePath is the root of the Redfish API defined by the standard
+const standardBasePath = "/redfish/v1"
+
+// basePathTransport reaches a Redfish API served under a non-standard base path, e.g. by an
+// API gateway, by moving every request below the standard root to the base path
+type basePathTransport struct {
+	next     http.RoundTripper
+	basePath string
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *basePathTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rest, ok := strings.CutPrefix(req.URL.Path, standardBasePath); ok && (rest == "" || rest[0] == '/') {
+		req = req.Clone(req.Context())
+		req.URL.Path = t.basePath + rest
+		req.URL.RawPath = ""
+	}
+	return t.next.RoundTrip(req)
+}
+
+// headerTransport sets the OData-Version and Accept headers some BMCs insist on
+type headerTransport struct {
+	next         http.RoundTripper
+	odataVersion string
+	accept       string
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.odataVersion != "" {
+		req.Header.Set("OData-Version", t.odataVersion)
+	}
+	if t.accept != "" {
+		req.Header.Set("Accept", t.accept)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// retryAfter parses a Retry-After header value, either in seconds or as an HTTP date, capped at max
+func retryAfter(value string, max time.Duration) time.Duration {
+	wait := time.Second
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		wait = time.Until(date)
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > max {
+		wait = max
+	}
+	return wait
+}
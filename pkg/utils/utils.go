@@ -1,9 +1,36 @@
 package utils
 
-import "math"
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"unicode/utf8"
+)
 
 // Round rounds a float64 to n decimal places
 func Round(num float64, places int) float64 {
 	multiplier := math.Pow(10, float64(places))
 	return math.Round(num*multiplier) / multiplier
 }
+
+// hashSuffixLength is the number of hex digits of the hash appended to truncated strings
+const hashSuffixLength = 6
+
+// Truncate shortens a string to at most max characters, marking truncation with an ellipsis
+// followed by a short hash of the whole string, so that strings sharing a long prefix stay
+// distinct. A max of zero or less disables truncation.
+func Truncate(s string, max int) string {
+	if max <= 0 || utf8.RuneCountInString(s) <= max {
+		return s
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	suffix := fmt.Sprintf("%08x", h.Sum32())[:hashSuffixLength]
+	if max <= hashSuffixLength+1 {
+		return suffix[:max]
+	}
+
+	runes := []rune(s)
+	return string(runes[:max-hashSuffixLength-1]) + "…" + suffix
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	for _, tt := range []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"CPU1 Temp", 0, "CPU1 Temp"},
+		{"CPU1 Temp", 9, "CPU1 Temp"},
+		{"CPU1 Temp", 20, "CPU1 Temp"},
+	} {
+		if got := Truncate(tt.s, tt.max); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateKeepsValuesDistinct(t *testing.T) {
+	const max = 16
+	a := Truncate("System Board Inlet Temperature 1", max)
+	b := Truncate("System Board Inlet Temperature 2", max)
+
+	if a == b {
+		t.Errorf("both values truncate to %q", a)
+	}
+	for _, s := range []string{a, b, Truncate("Ĉĉĉĉĉĉĉĉĉĉĉĉĉĉĉĉĉĉĉĉ", max)} {
+		if n := utf8.RuneCountInString(s); n != max {
+			t.Errorf("%q has %d characters, want %d", s, n, max)
+		}
+	}
+	if !strings.HasPrefix(a, "System Bo…") {
+		t.Errorf("%q doesn't keep the prefix of the value", a)
+	}
+}
+
+func TestTruncateShortLimit(t *testing.T) {
+	a, b := Truncate("Fan 1 Speed", 4), Truncate("Fan 2 Speed", 4)
+	if len(a) != 4 || len(b) != 4 || a == b {
+		t.Errorf("got %q and %q, want distinct values of 4 characters", a, b)
+	}
+}